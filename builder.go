@@ -0,0 +1,51 @@
+package erreur
+
+import (
+	"go.uber.org/zap"
+)
+
+// Builder builds a structured error in place, for code that adds lots of fields one at a time, e.g.
+// in a loop. Unlike WithFields, which has to copy the fields whenever it runs out of room since
+// Structured is immutable, adding fields to a Builder only appends them, and Freeze returns the
+// finished error without copying them:
+// 	b := erreur.NewBuilder(err, "validation failed")
+// 	for _, p := range problems {
+// 		b.Add(zap.String(p.Field, p.Reason))
+// 	}
+// 	return b.Freeze()
+// A Builder isn't safe for concurrent use, but the errors Freeze returns are as safe to share as any
+// other structured error.
+type Builder struct {
+	s Structured
+}
+
+// NewBuilder returns a Builder for an error with the given message and cause, like the ones New
+// (for a nil cause) and Wrap return. The call stack and creation time are captured when NewBuilder
+// is called, in the same cases New and Wrap capture them
+func NewBuilder(cause error, message string) *Builder {
+	s := Structured{causer: cause, err: String(message), fields: withCreationFields(nil)}
+	if captureStacks && (cause == nil || !hasStack(cause)) {
+		s.stack = callers(0)
+	}
+	return &Builder{s: s}
+}
+
+// Add appends fields to the fields of the error being built, and returns b for chaining
+func (b *Builder) Add(fields ...zap.Field) *Builder {
+	b.s.fields = append(b.s.fields, fields...)
+	return b
+}
+
+// Len returns the number of fields added so far
+func (b *Builder) Len() int {
+	return len(b.s.fields)
+}
+
+// Freeze returns the error built so far. b can still be used afterwards: fields added later don't
+// affect the returned error, so Freeze can e.g. be called once per stage of a long operation
+func (b *Builder) Freeze() Structured {
+	s := b.s
+	// cap the slice so that later appends to b's fields can't write into the frozen error's
+	s.fields = s.fields[:len(s.fields):len(s.fields)]
+	return s
+}
@@ -0,0 +1,141 @@
+package erreur
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder(New("dial failed"), "query failed").Add(zap.String("table", "users"))
+	first := b.Freeze()
+	b.Add(zap.Int("port", 5432), zap.Bool("retried", true))
+	second := b.Freeze()
+
+	const wantFirst = `{"msg":"query failed","table":"users","cause":{"msg":"dial failed"}}` + "\n"
+	if got := first.JSON(); got != wantFirst {
+		t.Errorf("got\n%s\nwant\n%s", got, wantFirst)
+	}
+	want := mustStructured(t, Wrap(New("dial failed"), "query failed", zap.String("table", "users"), zap.Int("port", 5432), zap.Bool("retried", true)))
+	if !Equal(second, want) {
+		t.Errorf("got %s, want %s", second.JSON(), want.JSON())
+	}
+
+	// appending to a frozen error must not reach into the builder's slice or vice versa
+	withExtra := first.WithFields(zap.String("extra", "x"))
+	b.Add(zap.String("later", "y"))
+	if got := first.JSON(); got != wantFirst {
+		t.Errorf("the first frozen error changed:\n%s", got)
+	}
+	if _, ok := withExtra.field("later"); ok || b.Len() != 4 {
+		t.Errorf("fields leaked between the builder and a frozen error: %s", withExtra.JSON())
+	}
+
+	leaf := NewBuilder(nil, "no cause").Add(zap.Int("n", 1)).Freeze()
+	if !Equal(leaf, New("no cause", zap.Int("n", 1))) {
+		t.Errorf("got %s for a builder without a cause", leaf.JSON())
+	}
+}
+
+func TestStructured_WithFields(t *testing.T) {
+	orig := mustStructured(t, New("query failed", zap.String("table", "users")))
+	got := orig.WithFields(zap.Int("port", 5432), zap.Bool("retried", true))
+	if !Equal(got, New("query failed", zap.String("table", "users"), zap.Int("port", 5432), zap.Bool("retried", true))) {
+		t.Errorf("got %s", got.JSON())
+	}
+	if len(orig.fields) != 1 {
+		t.Errorf("the original error was modified: %s", orig.JSON())
+	}
+
+	// only one of the errors sharing got's fields can append to them in place, so branching off
+	// the same error must not clobber the other branch's fields
+	a := got.WithFields(zap.String("branch", "a"))
+	b := got.WithFields(zap.String("branch", "b"))
+	aa := a.WithFields(zap.Int("depth", 2))
+	if f, _ := a.field("branch"); f.String != "a" {
+		t.Errorf("got branch %q for the first branch", f.String)
+	}
+	if f, _ := b.field("branch"); f.String != "b" {
+		t.Errorf("got branch %q for the second branch", f.String)
+	}
+	if f, _ := aa.field("branch"); f.String != "a" || len(aa.fields) != 5 || len(got.fields) != 3 {
+		t.Errorf("fields leaked between branches: %s", aa.JSON())
+	}
+}
+
+func TestStructured_WithFields_concurrent(t *testing.T) {
+	base := mustStructured(t, New("query failed")).WithFields(zap.String("table", "users"))
+
+	var wg sync.WaitGroup
+	results := make([]Structured, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s := base
+			for j := 0; j < 10; j++ {
+				s = s.WithFields(zap.Int("worker", i))
+			}
+			results[i] = s
+		}(i)
+	}
+	wg.Wait()
+
+	for i, s := range results {
+		for _, f := range s.fields[1:] {
+			if f.Integer != int64(i) {
+				t.Fatalf("worker %d got a field of worker %d", i, f.Integer)
+			}
+		}
+	}
+}
+
+func TestStructured_WithFields_allocs(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		s := Structured{err: String("validation failed")}
+		for j, key := range benchKeys {
+			s = s.WithFields(zap.Int(key, j))
+		}
+		benchBuilt = s
+	})
+	// the fields are copied only when they run out of room, and their capacity doubles every time
+	if allocs > 2*7 {
+		t.Errorf("got %v allocations for %d WithFields calls", allocs, benchFieldCount)
+	}
+}
+
+const benchFieldCount = 50
+
+var benchKeys = func() []string {
+	keys := make([]string, benchFieldCount)
+	for i := range keys {
+		keys[i] = "field" + strconv.Itoa(i)
+	}
+	return keys
+}()
+
+var benchBuilt Structured
+
+func BenchmarkWithFields(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s := New("validation failed").(Structured)
+		for j, key := range benchKeys {
+			s = s.WithFields(zap.Int(key, j))
+		}
+		benchBuilt = s
+	}
+}
+
+func BenchmarkBuilder(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bld := NewBuilder(nil, "validation failed")
+		for j, key := range benchKeys {
+			bld.Add(zap.Int(key, j))
+		}
+		benchBuilt = bld.Freeze()
+	}
+}
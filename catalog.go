@@ -0,0 +1,52 @@
+package erreur
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// catalogEntry is a code registered with RegisterCode
+type catalogEntry struct {
+	message string
+	level   zapcore.Level
+}
+
+// catalog holds the codes registered with RegisterCode
+var catalog = map[string]catalogEntry{}
+
+// unknownCodeMessage is the message of errors created by FromCatalog for unregistered codes
+const unknownCodeMessage = "unknown error"
+
+// RegisterCode adds code to the error catalog used by FromCatalog, with the message and level that
+// errors with that code get. Registering a code again replaces the earlier registration. A good
+// place to do this is a package-level var block or init function next to where the codes are
+// defined:
+// 	func init() {
+// 		erreur.RegisterCode("USER_NOT_FOUND", "user not found", zapcore.WarnLevel)
+// 	}
+func RegisterCode(code, defaultMessage string, level zapcore.Level) {
+	catalog[code] = catalogEntry{message: defaultMessage, level: level}
+}
+
+// FromCatalog returns a new structured error for a code registered with RegisterCode, with the
+// registered message, the given fields, and the code and level stored like WithCode and WithLevel
+// would store them. If code hasn't been registered, the error gets a generic message and the error
+// level, but still carries the code. Like New, the call stack is captured if the environment
+// variable in StackEnvVar is set
+func FromCatalog(code string, fields ...zap.Field) error {
+	ce, ok := catalog[code]
+	if !ok {
+		ce = catalogEntry{message: unknownCodeMessage, level: zapcore.ErrorLevel}
+	}
+
+	fs := make([]zap.Field, 0, len(fields)+4)
+	fs = append(fs, fields...)
+	fs = append(fs, zap.String(codeKey, code), zap.String(levelKey, ce.level.String()))
+	fs = appendCreationFields(fs)
+
+	s := Structured{err: String(ce.message), fields: fs}
+	if captureStacks {
+		s.stack = callers(0)
+	}
+	return s
+}
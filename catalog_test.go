@@ -0,0 +1,69 @@
+package erreur
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFromCatalog(t *testing.T) {
+	RegisterCode("USER_NOT_FOUND", "user not found", zapcore.WarnLevel)
+	RegisterCode("DB_DOWN", "database unavailable", zapcore.ErrorLevel)
+	defer func() {
+		delete(catalog, "USER_NOT_FOUND")
+		delete(catalog, "DB_DOWN")
+	}()
+
+	tests := []struct {
+		name      string
+		err       error
+		wantJSON  string
+		wantLevel zapcore.Level
+	}{
+		{
+			"registered",
+			FromCatalog("USER_NOT_FOUND", zap.Int("userID", 1234)),
+			`{"msg":"user not found","userID":1234,"code":"USER_NOT_FOUND","level":"warn"}`,
+			zapcore.WarnLevel,
+		},
+		{
+			"no fields",
+			FromCatalog("DB_DOWN"),
+			`{"msg":"database unavailable","code":"DB_DOWN","level":"error"}`,
+			zapcore.ErrorLevel,
+		},
+		{
+			"unknown",
+			FromCatalog("NO_SUCH_CODE", zap.String("user", "bob")),
+			`{"msg":"unknown error","user":"bob","code":"NO_SUCH_CODE","level":"error"}`,
+			zapcore.ErrorLevel,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mustStructured(t, tt.err).JSON(); got != tt.wantJSON+"\n" {
+				t.Errorf("got JSON\n%s\nwant\n%s", got, tt.wantJSON)
+			}
+			if level, ok := LevelOf(tt.err); !ok || level != tt.wantLevel {
+				t.Errorf("LevelOf returned %v, %v, want %v", level, ok, tt.wantLevel)
+			}
+		})
+	}
+
+	if code, _ := CodeOf(Wrap(FromCatalog("USER_NOT_FOUND"), "loading profile")); code != "USER_NOT_FOUND" {
+		t.Errorf("CodeOf returned %q through a wrapper", code)
+	}
+}
+
+func TestFromCatalog_doesNotModifyFields(t *testing.T) {
+	fields := make([]zap.Field, 1, 10)
+	fields[0] = zap.String("table", "users")
+
+	_ = FromCatalog("USER_NOT_FOUND", fields...)
+
+	if extra := fields[:cap(fields)][1]; extra.Key != "" {
+		t.Errorf("caller's fields were appended to: %v", extra)
+	}
+}
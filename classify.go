@@ -0,0 +1,64 @@
+package erreur
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// Codes Classify gives the standard library's sentinel errors
+const (
+	CodeNoRows           = "NO_ROWS"
+	CodeEOF              = "EOF"
+	CodeNotExist         = "NOT_EXIST"
+	CodeDeadlineExceeded = "DEADLINE_EXCEEDED"
+	CodeCanceled         = "CANCELED"
+)
+
+// classification is the code and category Classify gives a sentinel
+type classification struct {
+	sentinel error
+	code     string
+	category string
+}
+
+// classifications lists the sentinels Classify recognizes, in the order they're checked
+var classifications = []classification{
+	{sql.ErrNoRows, CodeNoRows, "not_found"},
+	{os.ErrNotExist, CodeNotExist, "not_found"},
+	{io.EOF, CodeEOF, "io"},
+	{context.DeadlineExceeded, CodeDeadlineExceeded, "timeout"},
+	{context.Canceled, CodeCanceled, "canceled"},
+}
+
+// Classify returns err as a structured error with a code and a category for the common standard
+// library sentinel errors err is or wraps (checked with errors.Is):
+// 	sentinel                  code                 category
+// 	sql.ErrNoRows             NO_ROWS              not_found
+// 	os.ErrNotExist            NOT_EXIST            not_found
+// 	io.EOF                    EOF                  io
+// 	context.DeadlineExceeded  DEADLINE_EXCEEDED    timeout
+// 	context.Canceled          CANCELED             canceled
+// The code is stored like WithCode stores it, and the category under the "category" key. err is
+// kept as the cause, and like with Structure, its message stays the message of the error:
+// 	{"msg":"sql: no rows in result set","code":"NO_ROWS","category":"not_found"}
+// Other errors are returned as structured errors with no code, or as-is if they're already
+// structured. Returns nil if err is nil
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, c := range classifications {
+		if errors.Is(err, c.sentinel) {
+			return Structured{causer: err, fields: []zap.Field{zap.String(codeKey, c.code), zap.String(categoryKey, c.category)}}
+		}
+	}
+	if _, ok := err.(Structured); ok {
+		return err
+	}
+	return Structure(err)
+}
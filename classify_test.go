@@ -0,0 +1,62 @@
+package erreur
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	_, notExist := os.Open("/no/such/file")
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode string
+		wantJSON string
+	}{
+		{"sql.ErrNoRows", sql.ErrNoRows, CodeNoRows,
+			`{"msg":"sql: no rows in result set","code":"NO_ROWS","category":"not_found"}`},
+		{"os.ErrNotExist", notExist, CodeNotExist,
+			`{"msg":"open /no/such/file: no such file or directory","code":"NOT_EXIST","category":"not_found"}`},
+		{"io.EOF", fmt.Errorf("reading header: %w", io.EOF), CodeEOF,
+			`{"msg":"reading header: EOF","code":"EOF","category":"io"}`},
+		{"context.DeadlineExceeded", context.DeadlineExceeded, CodeDeadlineExceeded,
+			`{"msg":"context deadline exceeded","code":"DEADLINE_EXCEEDED","category":"timeout"}`},
+		{"context.Canceled", context.Canceled, CodeCanceled,
+			`{"msg":"context canceled","code":"CANCELED","category":"canceled"}`},
+		{"unknown", errors.New("boom"), "", `{"msg":"boom"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Classify(tt.err)
+			if got := mustStructured(t, err).JSON(); got != tt.wantJSON+"\n" {
+				t.Errorf("got JSON\n%s\nwant\n%s", got, tt.wantJSON)
+			}
+			if code, _ := CodeOf(err); code != tt.wantCode {
+				t.Errorf("got code %q, want %q", code, tt.wantCode)
+			}
+			if !errors.Is(err, tt.err) || err.Error() != tt.err.Error() {
+				t.Errorf("the original error wasn't kept: %v", err)
+			}
+		})
+	}
+
+	if !errors.Is(Classify(notExist), fs.ErrNotExist) {
+		t.Error("errors.Is doesn't see through the classification")
+	}
+
+	stre := New("already structured")
+	if got := Classify(stre); !Equal(got, stre) {
+		t.Errorf("got %v for an unknown structured error, want it as-is", got)
+	}
+	if Classify(nil) != nil {
+		t.Error("got a non-nil error for nil")
+	}
+}
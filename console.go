@@ -0,0 +1,65 @@
+package erreur
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// consoleColor controls whether ConsoleString colorizes its output
+var consoleColor = false
+
+// SetConsoleColor controls whether ConsoleString colors its output with ANSI escape codes. The
+// default is false, i.e. no escape codes, since they only make sense on a terminal.
+func SetConsoleColor(color bool) {
+	consoleColor = color
+}
+
+// ANSI color codes, the same ones zap's capital color level encoder uses
+const (
+	ansiRed     = "\x1b[31m"
+	ansiYellow  = "\x1b[33m"
+	ansiBlue    = "\x1b[34m"
+	ansiMagenta = "\x1b[35m"
+	ansiReset   = "\x1b[0m"
+)
+
+// ConsoleString returns err on a single line for reading on a console while developing: its
+// message along with the fields of every error in the chain, like Error() with
+// IncludeFieldsInError set:
+// 	loading user {userID=1234}: query failed {table=users}: no rows
+// If SetConsoleColor is on, the line is colored by the level of err (see LevelOf) like zap colors
+// levels: red for error and above, yellow for warn, blue for info and magenta for debug. Errors with
+// no level are red. Returns an empty string if err is nil.
+//
+// The result is meant to be printed as-is, or used as the message of a log entry with zap's console
+// encoder, which writes messages unescaped:
+// 	logger.Error(erreur.ConsoleString(err))
+// Fields don't work for this, since the console encoder JSON-escapes the escape codes in them
+func ConsoleString(err error) string {
+	if err == nil {
+		return ""
+	}
+	var msg string
+	if stre, ok := err.(Structured); ok {
+		msg = stre.errorString(true)
+	} else {
+		msg = err.Error()
+	}
+	if stripControlChars {
+		msg = stripControl(msg)
+	}
+	if !consoleColor {
+		return msg
+	}
+
+	level, _ := LevelOf(err)
+	color := ansiRed
+	switch level {
+	case zapcore.DebugLevel:
+		color = ansiMagenta
+	case zapcore.InfoLevel:
+		color = ansiBlue
+	case zapcore.WarnLevel:
+		color = ansiYellow
+	}
+	return color + msg + ansiReset
+}
@@ -0,0 +1,54 @@
+package erreur
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var ansiCode = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestConsoleString(t *testing.T) {
+	err := mustStructured(t, Wrap(Wrap(String("no rows"), "query failed", zap.String("table", "users")), "loading user", zap.Int("userID", 1234)))
+	const want = "loading user {userID=1234}: query failed {table=users}: no rows"
+
+	if got := ConsoleString(err); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := ConsoleString(String("EOF")); got != "EOF" {
+		t.Errorf("got %q for a plain error", got)
+	}
+	if got := ConsoleString(nil); got != "" {
+		t.Errorf("got %q for nil", got)
+	}
+
+	SetConsoleColor(true)
+	defer SetConsoleColor(false)
+
+	tests := []struct {
+		name      string
+		err       error
+		wantColor string
+	}{
+		{"no level", err, ansiRed},
+		{"error", err.WithLevel(zapcore.ErrorLevel), ansiRed},
+		{"fatal", err.WithLevel(zapcore.FatalLevel), ansiRed},
+		{"warn", err.WithLevel(zapcore.WarnLevel), ansiYellow},
+		{"info", err.WithLevel(zapcore.InfoLevel), ansiBlue},
+		{"debug", err.WithLevel(zapcore.DebugLevel), ansiMagenta},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ConsoleString(tt.err)
+			if !strings.HasPrefix(got, tt.wantColor) || !strings.HasSuffix(got, ansiReset) {
+				t.Errorf("got %q, want it colored with %q", got, tt.wantColor)
+			}
+			if plain := ansiCode.ReplaceAllString(got, ""); !strings.HasPrefix(plain, "loading user {userID=1234") || !strings.HasSuffix(plain, "query failed {table=users}: no rows") {
+				t.Errorf("got %q without the color codes", plain)
+			}
+		})
+	}
+}
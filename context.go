@@ -0,0 +1,40 @@
+package erreur
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+// contextErrKey is the key of the field FromContextErr classifies the context error with
+const contextErrKey = "contextErr"
+
+// FromContextErr wraps the error of ctx (see context.Context.Err) with message and fields, for
+// operations that failed because their context was canceled or its deadline passed. The error is
+// classified in a "contextErr" field, which is "canceled" for context.Canceled and "deadline" for
+// context.DeadlineExceeded:
+// 	{"msg":"query aborted","table":"users","contextErr":"deadline"}
+// Returns nil if ctx.Err() is nil, i.e. ctx is still active. Like with Wrap, the call stack is
+// captured if the environment variable in StackEnvVar is set
+func FromContextErr(ctx context.Context, message string, fields ...zap.Field) error {
+	cause := ctx.Err()
+	if cause == nil {
+		return nil
+	}
+
+	fs := make([]zap.Field, 0, len(fields)+2)
+	fs = append(fs, fields...)
+	switch {
+	case errors.Is(cause, context.DeadlineExceeded):
+		fs = append(fs, zap.String(contextErrKey, "deadline"))
+	case errors.Is(cause, context.Canceled):
+		fs = append(fs, zap.String(contextErrKey, "canceled"))
+	}
+
+	s := Structured{causer: cause, err: String(message), fields: withCreationFields(fs)}
+	if captureStacks {
+		s.stack = callers(0)
+	}
+	return s
+}
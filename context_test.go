@@ -0,0 +1,51 @@
+package erreur
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestFromContextErr(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		want     string
+		wantErr  string
+		wantRoot error
+	}{
+		{"canceled", canceled,
+			`{"msg":"query aborted","table":"users","contextErr":"canceled"}`,
+			"query aborted: context canceled", context.Canceled},
+		{"deadline", expired,
+			`{"msg":"query aborted","table":"users","contextErr":"deadline"}`,
+			"query aborted: context deadline exceeded", context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := FromContextErr(tt.ctx, "query aborted", zap.String("table", "users"))
+			if got := mustStructured(t, err).JSON(); got != tt.want+"\n" {
+				t.Errorf("got JSON\n%s\nwant\n%s", got, tt.want)
+			}
+			if got := err.Error(); got != tt.wantErr {
+				t.Errorf("got Error() %q, want %q", got, tt.wantErr)
+			}
+			if !errors.Is(err, tt.wantRoot) {
+				t.Errorf("errors.Is(err, %v) returned false", tt.wantRoot)
+			}
+		})
+	}
+
+	if err := FromContextErr(context.Background(), "query aborted"); err != nil {
+		t.Errorf("got %v for an active context, want nil", err)
+	}
+}
@@ -0,0 +1,156 @@
+package erreur
+
+import (
+	"errors"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WrapCore returns a zapcore.Core that logs to core, but upgrades zap.Error fields (and other
+// fields of type zapcore.ErrorType, like zap.NamedError) holding a structured error to objects, like
+// Field does. This way existing zap.Error(err) call sites log the fields of structured errors
+// without having to be changed to use Field:
+// 	logger := zap.New(erreur.WrapCore(core))
+// 	logger.Error("failed to load data", zap.Error(err))
+// logs
+// 	{"msg":"failed to load data","error":{"msg":"connection error","code":1234}}
+// The key of the field is kept. Fields holding other errors are passed to core as-is. The fields
+// given to With are upgraded too. It can also be used with zap.WrapCore:
+// 	logger = logger.WithOptions(zap.WrapCore(erreur.WrapCore))
+func WrapCore(core zapcore.Core) zapcore.Core {
+	return structuredCore{core}
+}
+
+// structuredCore is the core returned by WrapCore
+type structuredCore struct {
+	zapcore.Core
+}
+
+// With implements zapcore.Core
+func (sc structuredCore) With(fields []zapcore.Field) zapcore.Core {
+	return structuredCore{sc.Core.With(upgradeErrorFields(fields))}
+}
+
+// Check implements zapcore.Core. The wrapped core decides whether and where the entry is written,
+// so that e.g. samplers and tees of cores with different levels work as usual, and the cores it
+// picks are written to through a checkedCore that upgrades the fields first
+func (sc structuredCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	inner := sc.Core.Check(ent, nil)
+	if inner == nil {
+		return ce
+	}
+	return ce.AddCore(ent, checkedCore{structuredCore: sc, inner: inner})
+}
+
+// Write implements zapcore.Core
+func (sc structuredCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return sc.Core.Write(ent, upgradeErrorFields(fields))
+}
+
+// checkedCore writes an entry to the cores the wrapped core of a structuredCore added to inner when
+// the entry was checked
+type checkedCore struct {
+	structuredCore
+	inner *zapcore.CheckedEntry
+}
+
+// Write implements zapcore.Core
+func (cc checkedCore) Write(_ zapcore.Entry, fields []zapcore.Field) error {
+	// CheckedEntry.Write doesn't return the errors of the cores, it reports them to its
+	// ErrorOutput, so collect them there
+	var errs writeErrors
+	cc.inner.ErrorOutput = &errs
+	cc.inner.Write(upgradeErrorFields(fields)...)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.TrimSpace(string(errs)))
+}
+
+// writeErrors is a zapcore.WriteSyncer that keeps what's written to it
+type writeErrors []byte
+
+// Write implements io.Writer
+func (we *writeErrors) Write(p []byte) (int, error) {
+	*we = append(*we, p...)
+	return len(p), nil
+}
+
+// Sync implements zapcore.WriteSyncer
+func (we *writeErrors) Sync() error {
+	return nil
+}
+
+// upgradeErrorFields returns fields with error fields holding structured errors replaced with
+// object fields. fields itself is only copied if something needs to be replaced, since it belongs
+// to the caller
+func upgradeErrorFields(fields []zapcore.Field) []zapcore.Field {
+	var upgraded []zapcore.Field
+	for i, f := range fields {
+		if f.Type != zapcore.ErrorType {
+			continue
+		}
+		err, ok := f.Interface.(error)
+		if !ok {
+			continue
+		}
+		stre, ok := liftStructured(err)
+		if !ok {
+			continue
+		}
+		if upgraded == nil {
+			upgraded = make([]zapcore.Field, len(fields))
+			copy(upgraded, fields)
+		}
+		upgraded[i] = zap.Object(f.Key, stre)
+	}
+	if upgraded == nil {
+		return fields
+	}
+	return upgraded
+}
+
+// RecordFields returns a zapcore.Core that logs to core, but also remembers the fields added to it
+// with With (e.g. by zap.Logger.With), so that CaptureLoggerFields can get them back. zap doesn't
+// expose the fields of a logger, so this lets the context a logger has been enriched with be put on
+// errors too:
+// 	logger := zap.New(erreur.RecordFields(core))
+// 	reqLogger := logger.With(zap.String("requestID", id))
+// 	...
+// 	err = erreur.Wrap(err, "request failed", erreur.CaptureLoggerFields(reqLogger.Core())...)
+// Since the fields are found by looking at the core, cores that wrap the returned core hide its
+// fields, so it should be the outermost core
+func RecordFields(core zapcore.Core) zapcore.Core {
+	return recordingCore{Core: core}
+}
+
+// recordingCore is the core returned by RecordFields
+type recordingCore struct {
+	zapcore.Core
+	fields []zapcore.Field
+}
+
+// With implements zapcore.Core
+func (rc recordingCore) With(fields []zapcore.Field) zapcore.Core {
+	// copy the fields instead of appending to rc.fields, which other cores created with With may
+	// share
+	all := make([]zapcore.Field, 0, len(rc.fields)+len(fields))
+	all = append(all, rc.fields...)
+	all = append(all, fields...)
+	return recordingCore{Core: rc.Core.With(fields), fields: all}
+}
+
+// CaptureLoggerFields returns the fields added with With to a core returned by RecordFields, e.g.
+// the core of a logger created with zap.New(erreur.RecordFields(core)). Returns nil for other
+// cores. The returned slice is a copy, so it can be modified freely
+func CaptureLoggerFields(core zapcore.Core) []zapcore.Field {
+	rc, ok := core.(recordingCore)
+	if !ok || len(rc.fields) == 0 {
+		return nil
+	}
+	fs := make([]zapcore.Field, len(rc.fields))
+	copy(fs, rc.fields)
+	return fs
+}
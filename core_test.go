@@ -0,0 +1,146 @@
+package erreur
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWrapCore(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(WrapCore(obs))
+
+	stre := New("connection error", zap.Int("code", 1234))
+	plain := errors.New("EOF")
+	logger.With(zap.NamedError("scoped", stre)).Error("failed to load data",
+		zap.Error(fmt.Errorf("loading: %w", stre)),
+		zap.NamedError("plain", plain),
+		zap.String("user", "bob"))
+
+	entries := logs.AllUntimed()
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	ctx := entries[0].ContextMap()
+
+	wantErr := map[string]interface{}{
+		"msg":   "loading",
+		"cause": map[string]interface{}{"msg": "connection error", "code": int64(1234)},
+	}
+	if got := fmt.Sprint(ctx["error"]); got != fmt.Sprint(wantErr) {
+		t.Errorf("got error field %s, want %s", got, fmt.Sprint(wantErr))
+	}
+	wantScoped := map[string]interface{}{"msg": "connection error", "code": int64(1234)}
+	if got := fmt.Sprint(ctx["scoped"]); got != fmt.Sprint(wantScoped) {
+		t.Errorf("got scoped field %s, want %s", got, fmt.Sprint(wantScoped))
+	}
+	if ctx["plain"] != "EOF" || ctx["user"] != "bob" {
+		t.Errorf("got unexpected plain fields %v", ctx)
+	}
+
+	fields := []zapcore.Field{zap.Error(stre)}
+	upgradeErrorFields(fields)
+	if fields[0].Type != zapcore.ErrorType {
+		t.Error("the caller's fields were modified")
+	}
+}
+
+func TestWrapCore_level(t *testing.T) {
+	obs, logs := observer.New(zapcore.WarnLevel)
+	logger := zap.New(WrapCore(obs))
+	logger.Info("ignored", zap.Error(New("a")))
+	logger.Warn("logged", zap.Error(New("b")))
+	if logs.Len() != 1 || logs.All()[0].Message != "logged" {
+		t.Errorf("got entries %v, want just the warning", logs.All())
+	}
+}
+
+func TestWrapCore_sampler(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(WrapCore(zapcore.NewSampler(obs, time.Second, 1, 100)))
+	for i := 0; i < 5; i++ {
+		logger.Error("failed to load data", zap.Error(New("connection error")))
+	}
+	if logs.Len() != 1 {
+		t.Fatalf("got %d entries, want the sampler to let through 1", logs.Len())
+	}
+	if _, ok := logs.All()[0].ContextMap()["error"].(map[string]interface{}); !ok {
+		t.Errorf("the sampled entry's error wasn't upgraded: %v", logs.All()[0].ContextMap())
+	}
+}
+
+func TestWrapCore_tee(t *testing.T) {
+	errObs, errLogs := observer.New(zapcore.ErrorLevel)
+	debugObs, debugLogs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(WrapCore(zapcore.NewTee(errObs, debugObs)))
+
+	logger.Info("retrying", zap.Error(New("timeout")))
+	logger.Error("failed to load data", zap.Error(New("connection error")))
+
+	if errLogs.Len() != 1 || errLogs.All()[0].Message != "failed to load data" {
+		t.Errorf("got entries %v in the error level core, want just the error", errLogs.All())
+	}
+	if debugLogs.Len() != 2 {
+		t.Errorf("got %d entries in the debug level core, want 2", debugLogs.Len())
+	}
+	for _, e := range append(errLogs.All(), debugLogs.All()...) {
+		if _, ok := e.ContextMap()["error"].(map[string]interface{}); !ok {
+			t.Errorf("the error of %q wasn't upgraded: %v", e.Message, e.ContextMap())
+		}
+	}
+}
+
+func TestRecordFields_sampler(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(RecordFields(zapcore.NewSampler(obs, time.Second, 1, 100))).With(zap.String("requestID", "abc"))
+	for i := 0; i < 5; i++ {
+		logger.Error("failed to load data")
+	}
+	if logs.Len() != 1 {
+		t.Fatalf("got %d entries, want the sampler to let through 1", logs.Len())
+	}
+	if logs.All()[0].ContextMap()["requestID"] != "abc" {
+		t.Errorf("got fields %v", logs.All()[0].ContextMap())
+	}
+	if fs := CaptureLoggerFields(logger.Core()); len(fs) != 1 {
+		t.Errorf("got captured fields %v", fs)
+	}
+}
+
+func TestCaptureLoggerFields(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(RecordFields(obs))
+	if fs := CaptureLoggerFields(logger.Core()); fs != nil {
+		t.Errorf("got fields %v for a logger without any", fs)
+	}
+
+	reqLogger := logger.With(zap.String("requestID", "abc")).With(zap.Int("attempt", 2))
+	other := logger.With(zap.String("requestID", "other"))
+
+	fs := CaptureLoggerFields(reqLogger.Core())
+	if got := fieldKeys(fs); !equalStrings(got, []string{"requestID", "attempt"}) {
+		t.Fatalf("got keys %v", got)
+	}
+	err := mustStructured(t, Wrap(New("dial failed"), "request failed", fs...))
+	const want = `{"msg":"request failed","requestID":"abc","attempt":2,"cause":{"msg":"dial failed"}}` + "\n"
+	if got := err.JSON(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if otherFs := CaptureLoggerFields(other.Core()); len(otherFs) != 1 || otherFs[0].String != "other" {
+		t.Errorf("fields leaked between loggers: %v", otherFs)
+	}
+
+	reqLogger.Info("still logs")
+	if logs.Len() != 1 || logs.All()[0].ContextMap()["requestID"] != "abc" {
+		t.Errorf("got entries %v, want one with the logger's fields", logs.All())
+	}
+
+	if fs := CaptureLoggerFields(obs); fs != nil {
+		t.Errorf("got fields %v for a core that doesn't record them", fs)
+	}
+}
@@ -0,0 +1,27 @@
+package erreur
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Overrun returns how far past deadline the current time is, or 0 if deadline hasn't passed yet
+func Overrun(deadline time.Time) time.Duration {
+	if d := now().Sub(deadline); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// WithDeadline returns a copy of s with deadline added under the "deadline" key, and the overrun
+// (see Overrun) at the time of the call in milliseconds under "overrunMs"
+func (s Structured) WithDeadline(deadline time.Time) Structured {
+	fs := make([]zap.Field, 0, len(s.fields)+2)
+	fs = append(fs, s.fields...)
+	s.fields = append(fs,
+		zap.Time("deadline", deadline),
+		zap.Int64("overrunMs", int64(Overrun(deadline)/time.Millisecond)),
+	)
+	return s
+}
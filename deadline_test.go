@@ -0,0 +1,44 @@
+package erreur
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// setNow makes now return t until the returned function is called
+func setNow(t time.Time) (reset func()) {
+	now = func() time.Time { return t }
+	return func() { now = time.Now }
+}
+
+func TestStructured_WithDeadline(t *testing.T) {
+	deadline := time.Date(2019, 7, 1, 12, 30, 0, 0, time.UTC)
+	defer setNow(deadline.Add(1500 * time.Millisecond))()
+
+	stre := mustStructured(t, New("rpc timed out", zap.String("method", "GetUser"))).WithDeadline(deadline)
+
+	const want = `{"msg":"rpc timed out","method":"GetUser","deadline":1561984200,"overrunMs":1500}` + "\n"
+	if got := stre.JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+	if got, ok := stre.TimeField("deadline"); !ok || !got.Equal(deadline) {
+		t.Errorf("got deadline %v, %v", got, ok)
+	}
+}
+
+func TestOverrun(t *testing.T) {
+	deadline := time.Date(2019, 7, 1, 12, 30, 0, 0, time.UTC)
+
+	reset := setNow(deadline.Add(-time.Second))
+	if got := Overrun(deadline); got != 0 {
+		t.Errorf("got overrun %v before the deadline, want 0", got)
+	}
+	reset()
+
+	defer setNow(deadline.Add(time.Second))()
+	if got := Overrun(deadline); got != time.Second {
+		t.Errorf("got overrun %v, want 1s", got)
+	}
+}
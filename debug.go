@@ -0,0 +1,228 @@
+package erreur
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// DebugString returns a multi-line, indented representation of s meant for humans, e.g. in test
+// failure messages. It contains the message of s, every field with its decoded value and type, and
+// the same recursively for the cause:
+// 	failed to flush db
+// 	  retries: 3 (int64)
+// 	  cause: writing to file failed
+// 	    fileName: "someFile" (string)
+// The errors of a multi-error cause, like one created with errors.Join, are listed as entries that
+// start with "- ", each with its own fields and cause.
+//
+// The format is not stable and shouldn't be parsed, use JSON() for that.
+func (s Structured) DebugString() string {
+	var sb strings.Builder
+	s.writeDebug(&sb, "")
+	return sb.String()
+}
+
+func (s Structured) writeDebug(sb *strings.Builder, indent string) {
+	sb.WriteString(treeLine(s.errorOrCause()))
+
+	fieldIndent := indent + "  "
+	for _, f := range s.fields {
+		switch f.Type {
+		case zapcore.SkipType:
+			continue
+		case zapcore.NamespaceType:
+			fmt.Fprintf(sb, "%s%s: (namespace)\n", fieldIndent, f.Key)
+			continue
+		}
+
+		v, _ := fieldValue(f)
+		if str, ok := v.(string); ok {
+			fmt.Fprintf(sb, "%s%s: %q (%s)\n", fieldIndent, f.Key, str, fieldTypeName(f.Type))
+		} else {
+			fmt.Fprintf(sb, "%s%s: %v (%s)\n", fieldIndent, f.Key, v, fieldTypeName(f.Type))
+		}
+	}
+
+	if s.causer == nil {
+		return
+	}
+	if multi, ok := s.causer.(multiWrapper); ok {
+		// like in serialization, the errors of an error created with Combine are its own
+		key := "cause"
+		if s.err == nil {
+			key = "errors"
+		}
+		fmt.Fprintf(sb, "%s%s:\n", fieldIndent, key)
+		for _, e := range multi.Unwrap() {
+			if e != nil {
+				writeDebugEntry(sb, e, fieldIndent+"  ")
+			}
+		}
+		return
+	}
+	// like in serialization, structured errors inside non-structured wrappers aren't lost
+	if cause, ok := s.structuredCause(); ok {
+		sb.WriteString(fieldIndent + "cause: ")
+		cause.writeDebug(sb, fieldIndent)
+	} else if s.err != nil {
+		// an error created with Structure already shows the message of a plain cause as its own
+		sb.WriteString(fieldIndent + "cause: " + treeLine(s.causer.Error()))
+	}
+}
+
+// writeDebugEntry writes err as an entry of a list of joined errors in DebugString
+func writeDebugEntry(sb *strings.Builder, err error, indent string) {
+	sb.WriteString(indent + "- ")
+	stre, ok := liftStructured(err)
+	if _, multi := err.(multiWrapper); multi {
+		// errors.Join and the like have no message of their own
+		stre, ok = Structured{causer: err}, true
+	}
+	if !ok {
+		sb.WriteString(treeLine(err.Error()))
+		return
+	}
+	stre.writeDebug(sb, indent)
+}
+
+var fieldTypeNames = map[zapcore.FieldType]string{
+	zapcore.ArrayMarshalerType:  "array",
+	zapcore.ObjectMarshalerType: "object",
+	zapcore.BinaryType:          "binary",
+	zapcore.BoolType:            "bool",
+	zapcore.ByteStringType:      "bytestring",
+	zapcore.Complex128Type:      "complex128",
+	zapcore.Complex64Type:       "complex64",
+	zapcore.DurationType:        "duration",
+	zapcore.Float64Type:         "float64",
+	zapcore.Float32Type:         "float32",
+	zapcore.Int64Type:           "int64",
+	zapcore.Int32Type:           "int32",
+	zapcore.Int16Type:           "int16",
+	zapcore.Int8Type:            "int8",
+	zapcore.StringType:          "string",
+	zapcore.TimeType:            "time",
+	zapcore.Uint64Type:          "uint64",
+	zapcore.Uint32Type:          "uint32",
+	zapcore.Uint16Type:          "uint16",
+	zapcore.Uint8Type:           "uint8",
+	zapcore.UintptrType:         "uintptr",
+	zapcore.ReflectType:         "reflect",
+	zapcore.NamespaceType:       "namespace",
+	zapcore.StringerType:        "stringer",
+	zapcore.ErrorType:           "error",
+	zapcore.SkipType:            "skip",
+}
+
+// fieldTypeName returns a short human-readable name for t
+func fieldTypeName(t zapcore.FieldType) string {
+	if name, ok := fieldTypeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Tree returns an ASCII tree of s and its causes, with one line per error that has its message and
+// fields, branching on the errors of multi-error causes:
+// 	sync failed {shard=3}
+// 	├── timeout {after=5s}
+// 	└── write failed
+// 	    └── disk full {free=0}
+// Like DebugString, this is meant for humans, and the format shouldn't be parsed
+func (s Structured) Tree() string {
+	var sb strings.Builder
+	sb.WriteString(treeLabel(s))
+	writeTreeChildren(&sb, treeChildren(s), "")
+	return sb.String()
+}
+
+// treeLabel returns the line of s in Tree
+func treeLabel(s Structured) string {
+	return treeLine(s.errorOrCause() + compactFields(s.fields))
+}
+
+// treeLine returns msg as a single line, since e.g. the messages of errors.Join are multi-line
+func treeLine(msg string) string {
+	return strings.ReplaceAll(msg, "\n", "; ") + "\n"
+}
+
+// treeChildren returns the causes of s shown as its children in Tree
+func treeChildren(s Structured) []error {
+	switch cause := s.causer.(type) {
+	case nil:
+		return nil
+	case Structured:
+		return []error{cause}
+	case multiWrapper:
+		return cause.Unwrap()
+	default:
+		if s.err == nil {
+			// an error created with Structure already shows the message of a plain cause as its own
+			return nil
+		}
+		return []error{cause}
+	}
+}
+
+func writeTreeChildren(sb *strings.Builder, children []error, indent string) {
+	for i, child := range children {
+		branch, childIndent := "├── ", indent+"│   "
+		if i == len(children)-1 {
+			branch, childIndent = "└── ", indent+"    "
+		}
+		sb.WriteString(indent + branch)
+
+		stre, ok := child.(Structured)
+		if !ok {
+			if _, multi := child.(multiWrapper); multi {
+				// errors.Join and the like have no message of their own, so show them as a node
+				// with just the branches
+				stre, ok = Structured{causer: child}, true
+			} else {
+				stre, ok = liftStructured(child)
+			}
+		}
+		if !ok {
+			sb.WriteString(treeLine(child.Error()))
+			continue
+		}
+		sb.WriteString(treeLabel(stre))
+		writeTreeChildren(sb, treeChildren(stre), childIndent)
+	}
+}
+
+// Summary returns the messages of s and its causes on a single line, from s to the root cause
+// joined with " <- ", and shortened to at most maxLen characters (runes) with a trailing "…", for
+// e.g. alerting systems that limit the length of messages:
+// 	request failed <- query failed <- dial failed
+// becomes "request failed <- query f…" with a maxLen of 26. Unlike Error(), the result doesn't
+// grow with the length of the chain. Fields aren't included, and maxLen <= 0 means no limit
+func (s Structured) Summary(maxLen int) string {
+	var msgs []string
+	var err error = s
+	for err != nil {
+		stre, ok := err.(Structured)
+		if _, multi := err.(multiWrapper); !ok && !multi {
+			stre, ok = liftStructured(err)
+		}
+		if !ok {
+			msgs = append(msgs, err.Error())
+			break
+		}
+		// errors created with Structure have no message of their own
+		if stre.err != nil {
+			msgs = append(msgs, stre.err.Error())
+		}
+		err = stre.causer
+	}
+
+	summary := strings.ReplaceAll(strings.Join(msgs, " <- "), "\n", "; ")
+	if maxLen <= 0 || utf8.RuneCountInString(summary) <= maxLen {
+		return summary
+	}
+	runes := []rune(summary)
+	return string(runes[:maxLen-1]) + "…"
+}
@@ -0,0 +1,158 @@
+package erreur
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestStructured_DebugString(t *testing.T) {
+	root := Wrap(String("insufficient permissions"), "writing to file failed",
+		zap.String("fileName", "someFile"), zap.Duration("took", 1500*time.Millisecond))
+	err := Wrap(root, "failed to flush db",
+		zap.Int("retries", 3), zap.Bool("fatal", false), zap.Strings("tables", []string{"a", "b"}))
+
+	const want = `failed to flush db
+  retries: 3 (int64)
+  fatal: false (bool)
+  tables: [a b] (array)
+  cause: writing to file failed
+    fileName: "someFile" (string)
+    took: 1.5s (duration)
+    cause: insufficient permissions
+`
+	if got := mustStructured(t, err).DebugString(); got != want {
+		t.Errorf("got DebugString\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestStructured_DebugString_structure(t *testing.T) {
+	err := Structure(String("insufficient permissions"), zap.String("user", "bob"))
+
+	const want = `insufficient permissions
+  user: "bob" (string)
+`
+	if got := mustStructured(t, err).DebugString(); got != want {
+		t.Errorf("got DebugString\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestStructured_DebugString_fmtWrapped(t *testing.T) {
+	err := Wrap(fmt.Errorf("layer: %w", New("dial failed", zap.String("host", "db"))), "query failed")
+
+	const want = `query failed
+  cause: layer
+    cause: dial failed
+      host: "db" (string)
+`
+	if got := mustStructured(t, err).DebugString(); got != want {
+		t.Errorf("got DebugString\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestStructured_DebugString_multi(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			"joined cause",
+			Wrap(errors.Join(
+				New("timeout", zap.Duration("after", 5*time.Second)),
+				Wrap(New("disk full", zap.Int("free", 0)), "write failed"),
+				errors.Join(String("a"), String("b")),
+			), "sync failed", zap.Int("shard", 3)),
+			`sync failed
+  shard: 3 (int64)
+  cause:
+    - timeout
+      after: 5s (duration)
+    - write failed
+      cause: disk full
+        free: 0 (int64)
+    - a; b
+      errors:
+        - a
+        - b
+`,
+		},
+		{
+			"combined",
+			Combine(New("a", zap.Int("n", 1)), String("b")),
+			`a; b
+  errors:
+    - a
+      n: 1 (int64)
+    - b
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mustStructured(t, tt.err).DebugString(); got != tt.want {
+				t.Errorf("got DebugString\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructured_Tree(t *testing.T) {
+	chain := mustStructured(t, Wrap(fmt.Errorf("layer: %w", New("dial failed", zap.String("host", "db"))), "query failed", zap.Int("port", 5432)))
+	const wantChain = `query failed {port=5432}
+└── layer
+    └── dial failed {host=db}
+`
+	if got := chain.Tree(); got != wantChain {
+		t.Errorf("got\n%s\nwant\n%s", got, wantChain)
+	}
+
+	joined := mustStructured(t, Wrap(errors.Join(
+		New("timeout", zap.Duration("after", 5*time.Second)),
+		Wrap(New("disk full", zap.Int("free", 0)), "write failed"),
+		errors.Join(String("a"), String("b")),
+	), "sync failed", zap.Int("shard", 3)))
+	const wantJoined = `sync failed {shard=3}
+├── timeout {after=5s}
+├── write failed
+│   └── disk full {free=0}
+└── a; b
+    ├── a
+    └── b
+`
+	if got := joined.Tree(); got != wantJoined {
+		t.Errorf("got\n%s\nwant\n%s", got, wantJoined)
+	}
+}
+
+func TestStructured_Summary(t *testing.T) {
+	chain := mustStructured(t, Wrap(fmt.Errorf("handler: %w", Wrap(Structure(String("dial failed"), zap.Int("n", 1)), "query failed", zap.String("table", "users"))), "request failed"))
+
+	tests := []struct {
+		maxLen int
+		want   string
+	}{
+		{0, "request failed <- handler <- query failed <- dial failed"},
+		{56, "request failed <- handler <- query failed <- dial failed"},
+		{55, "request failed <- handler <- query failed <- dial fail…"},
+		{20, "request failed <- h…"},
+		{1, "…"},
+	}
+	for _, tt := range tests {
+		if got := chain.Summary(tt.maxLen); got != tt.want {
+			t.Errorf("Summary(%d) = %q, want %q", tt.maxLen, got, tt.want)
+		}
+	}
+
+	joined := mustStructured(t, Wrap(errors.Join(String("timeout"), String("disk full")), "sync failed"))
+	if got := joined.Summary(0); got != "sync failed <- timeout; disk full" {
+		t.Errorf("got %q for a joined cause", got)
+	}
+	if got := mustStructured(t, New("ünïcödé message")).Summary(8); got != "ünïcödé…" {
+		t.Errorf("got %q, want the first runes", got)
+	}
+}
@@ -0,0 +1,51 @@
+package erreur
+
+import (
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// moreCausesKey is the key of the marker that replaces the causes JSONMaxDepth leaves out
+const moreCausesKey = "..."
+
+// JSONMaxDepth is like JSON, but only serializes the first n errors of the cause chain, starting
+// with s itself, to keep log entries of long chains readable. The causes that are left out are
+// replaced with a marker that says how many there are, so with n=2:
+// 	{"msg":"request failed","cause":{"msg":"query failed","...":"3 more causes"}}
+// If n is 0 or less, the whole chain is serialized like with JSON
+func (s Structured) JSONMaxDepth(n int) string {
+	if n < 0 {
+		n = 0
+	}
+	return string(copyAndFree(s.encodeJSONLevels(zapcore.NewJSONEncoder(jsonEncConf), n)))
+}
+
+// depthLimited serializes a structured error like MarshalLogObject does, but with only levels
+// errors of the chain
+type depthLimited struct {
+	s      Structured
+	levels int
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler
+func (dl depthLimited) MarshalLogObject(oe zapcore.ObjectEncoder) error {
+	oe.AddString(nestedMessageKey, serializedMessage(dl.s.errorOrCause()))
+	for _, f := range dl.s.limitedFields(dl.levels) {
+		f.AddTo(oe)
+	}
+	return nil
+}
+
+// moreCausesField returns the marker field for the causes that are left out, starting with s
+func moreCausesField(s Structured) zapcore.Field {
+	n := 1
+	for next, ok := s.structuredCause(); ok; next, ok = next.structuredCause() {
+		n++
+	}
+	if n == 1 {
+		return zap.String(moreCausesKey, "1 more cause")
+	}
+	return zap.String(moreCausesKey, strconv.Itoa(n)+" more causes")
+}
@@ -0,0 +1,50 @@
+package erreur
+
+import (
+	"strconv"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestStructured_JSONMaxDepth(t *testing.T) {
+	var err error = New("level 5", zap.Int("level", 5))
+	for i := 4; i >= 1; i-- {
+		err = Wrap(err, "level "+strconv.Itoa(i), zap.Int("level", i))
+	}
+	stre := mustStructured(t, err)
+
+	tests := []struct {
+		name string
+		n    int
+		want string
+	}{
+		{"capped at 2", 2,
+			`{"msg":"level 1","level":1,"cause":{"msg":"level 2","level":2,"...":"3 more causes"}}`},
+		{"capped at 1", 1,
+			`{"msg":"level 1","level":1,"...":"4 more causes"}`},
+		{"one left out", 4,
+			`{"msg":"level 1","level":1,"cause":{"msg":"level 2","level":2,"cause":{"msg":"level 3","level":3,` +
+				`"cause":{"msg":"level 4","level":4,"...":"1 more cause"}}}}`},
+		{"exact", 5, stre.JSON()[:len(stre.JSON())-1]},
+		{"unlimited", 0, stre.JSON()[:len(stre.JSON())-1]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stre.JSONMaxDepth(tt.n); got != tt.want+"\n" {
+				t.Errorf("got JSON\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("chain as array", func(t *testing.T) {
+		SetChainAsArray(true)
+		defer SetChainAsArray(false)
+
+		const want = `{"msg":"level 1","level":1,"chain":[{"msg":"level 2","level":2},{"...":"3 more causes"}]}` + "\n"
+		if got := stre.JSONMaxDepth(2); got != want {
+			t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+		}
+	})
+}
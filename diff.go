@@ -0,0 +1,116 @@
+package erreur
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Diff returns a human-readable description of how b differs from a, or an empty string if their
+// messages and fields are the same at every level of the cause chain. It's meant for test failure
+// messages:
+// 	if d := erreur.Diff(want, got); d != "" {
+// 		t.Errorf("unexpected error:\n%s", d)
+// 	}
+// Each difference is on its own line, prefixed with "cause." for the cause and so on like keys are
+// in AllFields:
+// 	msg: "query failed" != "request failed"
+// 	port: 5432 != 5433
+// 	user: removed (was "bob")
+// 	cause.attempt: added (3)
+// Errors that aren't structured are compared by their type and message only
+func Diff(a, b error) string {
+	var sb strings.Builder
+	writeDiff(&sb, a, b, "")
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func writeDiff(w *strings.Builder, a, b error, prefix string) {
+	if a == nil || b == nil {
+		name := strings.TrimSuffix(prefix, ".")
+		if name == "" {
+			name = "error"
+		}
+		switch {
+		case a != nil:
+			fmt.Fprintf(w, "%s: removed (was %q)\n", name, a.Error())
+		case b != nil:
+			fmt.Fprintf(w, "%s: added (%q)\n", name, b.Error())
+		}
+		return
+	}
+
+	sa, aIsStructured := a.(Structured)
+	sb, bIsStructured := b.(Structured)
+	if !aIsStructured || !bIsStructured {
+		if reflect.TypeOf(a) != reflect.TypeOf(b) {
+			fmt.Fprintf(w, "%stype: %T != %T\n", prefix, a, b)
+		}
+		if a.Error() != b.Error() {
+			fmt.Fprintf(w, "%s%s: %q != %q\n", prefix, nestedMessageKey, a.Error(), b.Error())
+		}
+		return
+	}
+
+	if ma, mb := ownMessage(sa), ownMessage(sb); ma != mb {
+		fmt.Fprintf(w, "%s%s: %s != %s\n", prefix, nestedMessageKey, ma, mb)
+	}
+	writeFieldDiff(w, sa.fields, sb.fields, prefix)
+	writeDiff(w, sa.causer, sb.causer, prefix+"cause.")
+}
+
+// ownMessage returns the quoted own message of s for Diff, or "(none)" if it was created with
+// Structure
+func ownMessage(s Structured) string {
+	if s.err == nil {
+		return "(none)"
+	}
+	return fmt.Sprintf("%q", s.err.Error())
+}
+
+func writeFieldDiff(w *strings.Builder, a, b []zapcore.Field, prefix string) {
+	av, bv := diffValues(a), diffValues(b)
+	for _, f := range a {
+		va, ok := av[f.Key]
+		if !ok {
+			continue
+		}
+		delete(av, f.Key) // so that duplicate keys are only reported once
+		vb, ok := bv[f.Key]
+		switch {
+		case !ok:
+			fmt.Fprintf(w, "%s%s: removed (was %s)\n", prefix, f.Key, va)
+		case va != vb:
+			fmt.Fprintf(w, "%s%s: %s != %s\n", prefix, f.Key, va, vb)
+		}
+		delete(bv, f.Key)
+	}
+	for _, f := range b {
+		if vb, ok := bv[f.Key]; ok {
+			delete(bv, f.Key)
+			fmt.Fprintf(w, "%s%s: added (%s)\n", prefix, f.Key, vb)
+		}
+	}
+}
+
+// diffValues returns the formatted values of the first field with each key in fs
+func diffValues(fs []zapcore.Field) map[string]string {
+	vs := make(map[string]string, len(fs))
+	for _, f := range fs {
+		if _, seen := vs[f.Key]; seen {
+			continue
+		}
+		v, ok := fieldValue(f)
+		if !ok {
+			continue
+		}
+		if str, isString := v.(string); isString {
+			vs[f.Key] = fmt.Sprintf("%q", str)
+		} else {
+			vs[f.Key] = fmt.Sprintf("%v", v)
+		}
+	}
+	return vs
+}
@@ -0,0 +1,75 @@
+package erreur
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestDiff(t *testing.T) {
+	base := Wrap(New("dial failed", zap.String("host", "db")), "query failed", zap.Int("port", 5432), zap.String("user", "bob"))
+
+	tests := []struct {
+		name string
+		a, b error
+		want string
+	}{
+		{"equal",
+			base,
+			Wrap(New("dial failed", zap.String("host", "db")), "query failed", zap.Int("port", 5432), zap.String("user", "bob")),
+			""},
+		{"field value",
+			base,
+			Wrap(New("dial failed", zap.String("host", "db")), "query failed", zap.Int("port", 5433), zap.String("user", "bob")),
+			"port: 5432 != 5433"},
+		{"field type",
+			base,
+			Wrap(New("dial failed", zap.String("host", "db")), "query failed", zap.String("port", "5432"), zap.String("user", "bob")),
+			`port: 5432 != "5432"`},
+		{"missing field",
+			base,
+			Wrap(New("dial failed", zap.String("host", "db")), "query failed", zap.Int("port", 5432)),
+			`user: removed (was "bob")`},
+		{"added field in cause",
+			base,
+			Wrap(New("dial failed", zap.String("host", "db"), zap.Int("attempt", 3)), "query failed", zap.Int("port", 5432), zap.String("user", "bob")),
+			"cause.attempt: added (3)"},
+		{"message",
+			base,
+			Wrap(New("dial failed", zap.String("host", "db")), "request failed", zap.Int("port", 5432), zap.String("user", "bob")),
+			`msg: "query failed" != "request failed"`},
+		{"several",
+			New("a", zap.Int("x", 1), zap.Int("y", 2)),
+			New("b", zap.Int("y", 3), zap.Int("z", 4)),
+			`msg: "a" != "b"` + "\n" + `x: removed (was 1)` + "\n" + `y: 2 != 3` + "\n" + `z: added (4)`},
+		{"plain cause",
+			Wrap(String("eof"), "read failed"),
+			Wrap(String("timeout"), "read failed"),
+			`cause.msg: "eof" != "timeout"`},
+		{"structured vs plain cause",
+			Wrap(New("eof"), "read failed"),
+			Wrap(String("eof"), "read failed"),
+			"cause.type: erreur.Structured != erreur.String"},
+		{"missing cause",
+			Wrap(String("eof"), "read failed"),
+			New("read failed"),
+			`cause: removed (was "eof")`},
+		{"structure vs new",
+			Structure(String("eof")),
+			Wrap(String("eof"), "eof"),
+			`msg: (none) != "eof"`},
+		{"nil",
+			nil,
+			New("failed"),
+			`error: added ("failed")`},
+		{"both nil", nil, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.a, tt.b); got != tt.want {
+				t.Errorf("got diff\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -25,5 +25,11 @@
 //
 //  // or alternatively
 //  json := connErr.JSON()
+//
+// Options
+//
+// The package-level options, i.e. the Set functions (such as SetErrorSeparator, SetConsoleColor or
+// RegisterCode) and exported variables (such as IncludeFieldsInError or AllowedHTTPHeaders), should
+// be set once during program initialization, as they're not safe for concurrent use.
 package erreur
 
@@ -0,0 +1,15 @@
+package erreur
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// FromEntry returns a structured error with the message of entry and the given fields, for turning
+// log entries into errors, e.g. in a custom zapcore.Core that intercepts them. The fields are
+// copied, so fields can safely be reused afterwards. Other parts of the entry like its level and
+// time are not included
+func FromEntry(entry zapcore.Entry, fields []zapcore.Field) Structured {
+	fs := make([]zapcore.Field, len(fields))
+	copy(fs, fields)
+	return Structured{err: String(entry.Message), fields: fs}
+}
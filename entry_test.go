@@ -0,0 +1,30 @@
+package erreur
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFromEntry(t *testing.T) {
+	entry := zapcore.Entry{
+		Level:      zapcore.ErrorLevel,
+		Time:       time.Date(2019, 7, 1, 12, 30, 0, 0, time.UTC),
+		LoggerName: "db",
+		Message:    "query failed",
+	}
+	fields := []zapcore.Field{zap.String("table", "users"), zap.Int("rows", 0)}
+
+	stre := FromEntry(entry, fields)
+	fields[0] = zap.String("table", "overwritten")
+
+	const want = `{"msg":"query failed","table":"users","rows":0}` + "\n"
+	if got := stre.JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+	if got := stre.Error(); got != "query failed" {
+		t.Errorf("got Error() %q", got)
+	}
+}
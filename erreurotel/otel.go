@@ -0,0 +1,57 @@
+// package erreurotel connects erreur's structured errors with OpenTelemetry tracing, so that errors
+// carry the IDs needed to correlate them with the trace they happened in. It lives in its own
+// package so that the core erreur package doesn't depend on OpenTelemetry.
+package erreurotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/ORBAT/erreur"
+)
+
+const (
+	// TraceIDKey is the field key used for trace IDs
+	TraceIDKey = "traceID"
+	// SpanIDKey is the field key used for span IDs
+	SpanIDKey = "spanID"
+)
+
+// FromSpanContext returns a new structured error with the given message and fields, plus traceID
+// and spanID fields taken from the span context active in ctx. If ctx has no valid span context,
+// FromSpanContext behaves exactly like erreur.New
+func FromSpanContext(ctx context.Context, message string, fields ...zap.Field) error {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return erreur.New(message, fields...)
+	}
+
+	fs := make([]zap.Field, 0, len(fields)+2)
+	fs = append(fs, fields...)
+	fs = append(fs, spanContextFields(sc)...)
+	return erreur.New(message, fs...)
+}
+
+func spanContextFields(sc trace.SpanContext) []zap.Field {
+	return []zap.Field{
+		zap.String(TraceIDKey, sc.TraceID().String()),
+		zap.String(SpanIDKey, sc.SpanID().String()),
+	}
+}
+
+// WithSpan returns a copy of s with traceID and spanID fields taken from the span context of span,
+// for correlating s with the trace it happened in when it's logged. Any previous trace and span IDs
+// of s are replaced. If span has no valid span context, e.g. because tracing isn't set up, s is
+// returned as-is
+func WithSpan(s erreur.Structured, span trace.Span) erreur.Structured {
+	sc := span.SpanContext()
+	if !sc.IsValid() {
+		return s
+	}
+	for _, f := range spanContextFields(sc) {
+		s = s.SetField(f)
+	}
+	return s
+}
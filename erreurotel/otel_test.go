@@ -0,0 +1,76 @@
+package erreurotel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/ORBAT/erreur"
+)
+
+var testSpanContext = trace.NewSpanContext(trace.SpanContextConfig{
+	TraceID:    trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+	SpanID:     trace.SpanID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+	TraceFlags: trace.FlagsSampled,
+})
+
+func TestFromSpanContext(t *testing.T) {
+	ctx := trace.ContextWithSpanContext(context.Background(), testSpanContext)
+	err := FromSpanContext(ctx, "query failed", zap.String("table", "users"))
+
+	stre, ok := erreur.AsStructured(err)
+	if !ok {
+		t.Fatalf("expected a structured error, got %T", err)
+	}
+
+	const want = `{"msg":"query failed","table":"users","traceID":"0102030405060708090a0b0c0d0e0f10","spanID":"0102030405060708"}` + "\n"
+	if got := stre.JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFromSpanContext_noSpan(t *testing.T) {
+	err := FromSpanContext(context.Background(), "query failed", zap.String("table", "users"))
+	stre, _ := erreur.AsStructured(err)
+	want, _ := erreur.AsStructured(erreur.New("query failed", zap.String("table", "users")))
+
+	if stre.JSON() != want.JSON() {
+		t.Errorf("got JSON %s, want %s", stre.JSON(), want.JSON())
+	}
+}
+
+func TestFromSpanContext_doesNotModifyFields(t *testing.T) {
+	ctx := trace.ContextWithSpanContext(context.Background(), testSpanContext)
+	fields := make([]zap.Field, 1, 10)
+	fields[0] = zap.String("table", "users")
+
+	_ = FromSpanContext(ctx, "query failed", fields...)
+
+	if extra := fields[:cap(fields)][1]; extra.Key != "" {
+		t.Errorf("caller's fields were appended to: %v", extra)
+	}
+}
+
+func TestWithSpan(t *testing.T) {
+	span := trace.SpanFromContext(trace.ContextWithSpanContext(context.Background(), testSpanContext))
+	stre, _ := erreur.AsStructured(erreur.Wrap(erreur.New("dial failed"), "query failed", zap.String("table", "users")))
+
+	got := WithSpan(stre, span)
+	if v, _ := got.StringField(TraceIDKey); v != "0102030405060708090a0b0c0d0e0f10" {
+		t.Errorf("got trace ID %q", v)
+	}
+	if v, _ := got.StringField(SpanIDKey); v != "0102030405060708" {
+		t.Errorf("got span ID %q", v)
+	}
+	const want = `{"msg":"query failed","table":"users","traceID":"0102030405060708090a0b0c0d0e0f10","spanID":"0102030405060708","cause":{"msg":"dial failed"}}` + "\n"
+	if got := got.JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+
+	noSpan := WithSpan(stre, trace.SpanFromContext(context.Background()))
+	if noSpan.JSON() != stre.JSON() {
+		t.Errorf("got JSON %s for a span with no valid context, want %s", noSpan.JSON(), stre.JSON())
+	}
+}
@@ -0,0 +1,6 @@
+// package erreurpb contains the protobuf representation of erreur's structured errors, for sending
+// errors in e.g. gRPC responses or Kafka messages. See erreur.Structured.ToProto and erreur.FromProto
+// for converting to and from it.
+package erreurpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative erreur.proto
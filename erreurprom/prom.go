@@ -0,0 +1,55 @@
+// Package erreurprom derives Prometheus labels from structured errors. It's a separate package so
+// that erreur itself doesn't depend on the Prometheus client
+package erreurprom
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ORBAT/erreur"
+)
+
+// Unknown is the label value used for labels whose field is missing from the error
+const Unknown = "unknown"
+
+// keys of the fields that are always included in the labels
+const (
+	codeLabel     = "code"
+	categoryLabel = "category"
+)
+
+// MetricLabels returns labels for counting err in a Prometheus metric. The labels always include
+// "code" (see erreur.CodeOf) and "category", plus one label for each of the given keys, with the
+// value of the field with that key in err's cause chain (see erreur.FieldValue). Labels whose field
+// is missing get the value Unknown, so that every error has the same set of labels and the label
+// cardinality stays bounded:
+// 	errorsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "errors_total"},
+// 		[]string{"code", "category", "operation"})
+// 	errorsTotal.With(erreurprom.MetricLabels(err, "operation")).Inc()
+// Non-string values are formatted with fmt.Sprint. The keys should be valid Prometheus label names
+func MetricLabels(err error, keys ...string) prometheus.Labels {
+	labels := make(prometheus.Labels, len(keys)+2)
+
+	labels[codeLabel] = Unknown
+	if code, ok := erreur.CodeOf(err); ok {
+		labels[codeLabel] = code
+	}
+	labels[categoryLabel] = labelValue(err, categoryLabel)
+
+	for _, key := range keys {
+		labels[key] = labelValue(err, key)
+	}
+	return labels
+}
+
+func labelValue(err error, key string) string {
+	v, ok := erreur.FieldValue(err, key)
+	if !ok {
+		return Unknown
+	}
+	if str, ok := v.(string); ok {
+		return str
+	}
+	return fmt.Sprint(v)
+}
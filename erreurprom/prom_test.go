@@ -0,0 +1,51 @@
+package erreurprom
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/ORBAT/erreur"
+)
+
+func TestMetricLabels(t *testing.T) {
+	inner, _ := erreur.AsStructured(erreur.New("query failed", zap.String("table", "users"), zap.Int("shard", 3)))
+	err := fmt.Errorf("handler: %w", inner.WithCode("DB_ERROR").WithOperation("GetUser"))
+
+	tests := []struct {
+		name string
+		err  error
+		keys []string
+		want prometheus.Labels
+	}{
+		{"some keys present", err, []string{"operation", "shard", "region"},
+			prometheus.Labels{"code": "DB_ERROR", "category": Unknown, "operation": "GetUser", "shard": "3", "region": Unknown}},
+		{"no keys", err, nil,
+			prometheus.Labels{"code": "DB_ERROR", "category": Unknown}},
+		{"category", erreur.New("no rows", zap.String("category", "not_found")), []string{"table"},
+			prometheus.Labels{"code": Unknown, "category": "not_found", "table": Unknown}},
+		{"plain", erreur.String("plain"), []string{"table"},
+			prometheus.Labels{"code": Unknown, "category": Unknown, "table": Unknown}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MetricLabels(tt.err, tt.keys...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricLabels_counterVec(t *testing.T) {
+	vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "errors_total"}, []string{"code", "category", "operation"})
+
+	for _, err := range []error{erreur.New("no labels"), erreur.String("plain")} {
+		if _, e := vec.GetMetricWith(MetricLabels(err, "operation")); e != nil {
+			t.Errorf("labels for %v don't match the counter: %v", err, e)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// package erreurtest provides test helpers for code that uses erreur's structured errors. On
+// failure they print the full DebugString of the error, which is a lot more informative than %v.
+// It's a separate package so that the erreur package doesn't depend on the testing package.
+package erreurtest
+
+import (
+	"testing"
+
+	"github.com/ORBAT/erreur"
+)
+
+// RequireNoError fails and stops the test if err is not nil
+func RequireNoError(t testing.TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", describe(err))
+	}
+}
+
+// AssertCode marks the test as failed if err doesn't have the given code according to
+// erreur.CodeOf. Returns true if the assertion held
+func AssertCode(t testing.TB, err error, code string) bool {
+	t.Helper()
+	if err == nil {
+		t.Errorf("expected an error with code %q, got nil", code)
+		return false
+	}
+
+	got, ok := erreur.CodeOf(err)
+	if !ok {
+		t.Errorf("expected an error with code %q, got an error with no code: %s", code, describe(err))
+		return false
+	}
+	if got != code {
+		t.Errorf("expected an error with code %q, got code %q: %s", code, got, describe(err))
+		return false
+	}
+	return true
+}
+
+// describe returns the message of err followed by the DebugString of the structured error in it,
+// if any
+func describe(err error) string {
+	stre, ok := erreur.AsStructured(err)
+	if !ok {
+		return err.Error()
+	}
+	return err.Error() + "\n" + stre.DebugString()
+}
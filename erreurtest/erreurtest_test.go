@@ -0,0 +1,91 @@
+package erreurtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/ORBAT/erreur"
+)
+
+// fakeTB records failures instead of failing the test
+type fakeTB struct {
+	testing.TB
+	failed, fatal bool
+	msg           string
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.failed = true
+	f.msg = fmt.Sprintf(format, args...)
+}
+
+func (f *fakeTB) Fatalf(format string, args ...interface{}) {
+	f.Errorf(format, args...)
+	f.fatal = true
+}
+
+func notFound() error {
+	stre, _ := erreur.AsStructured(erreur.New("user not found", zap.Int("userID", 1234)))
+	return erreur.Wrap(stre.WithCode("USER_NOT_FOUND"), "loading profile failed")
+}
+
+func TestRequireNoError(t *testing.T) {
+	tb := &fakeTB{}
+	RequireNoError(tb, nil)
+	if tb.failed {
+		t.Errorf("RequireNoError failed for a nil error: %s", tb.msg)
+	}
+
+	tb = &fakeTB{}
+	RequireNoError(tb, notFound())
+	if !tb.fatal {
+		t.Fatal("RequireNoError didn't fail fatally for an error")
+	}
+	const want = `unexpected error: loading profile failed: user not found
+loading profile failed
+  cause: user not found
+    userID: 1234 (int64)
+    code: "USER_NOT_FOUND" (string)
+`
+	if tb.msg != want {
+		t.Errorf("got failure message\n%s\nwant\n%s", tb.msg, want)
+	}
+}
+
+func TestAssertCode(t *testing.T) {
+	tb := &fakeTB{}
+	if !AssertCode(tb, notFound(), "USER_NOT_FOUND") || tb.failed {
+		t.Errorf("AssertCode failed for a matching code: %s", tb.msg)
+	}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantPrefix string
+	}{
+		{"wrong code", notFound(), `expected an error with code "FORBIDDEN", got code "USER_NOT_FOUND": loading profile failed: user not found` + "\nloading profile failed\n"},
+		{"no code", erreur.New("oops"), `expected an error with code "FORBIDDEN", got an error with no code: oops` + "\noops\n"},
+		{"plain", erreur.String("oops"), `expected an error with code "FORBIDDEN", got an error with no code: oops`},
+		{"nil", nil, `expected an error with code "FORBIDDEN", got nil`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := &fakeTB{}
+			if AssertCode(tb, tt.err, "FORBIDDEN") {
+				t.Error("AssertCode returned true")
+			}
+			if !tb.failed || tb.fatal {
+				t.Errorf("got failed=%v fatal=%v, want failed=true fatal=false", tb.failed, tb.fatal)
+			}
+			if !strings.HasPrefix(tb.msg, tt.wantPrefix) {
+				t.Errorf("got failure message\n%s\nwant it to start with\n%s", tb.msg, tt.wantPrefix)
+			}
+		})
+	}
+}
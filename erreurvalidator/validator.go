@@ -0,0 +1,52 @@
+// Package erreurvalidator turns validation errors from github.com/go-playground/validator into
+// structured errors. It's a separate package so that erreur itself doesn't depend on the validator
+package erreurvalidator
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+
+	"github.com/ORBAT/erreur"
+)
+
+// Message is the message of the errors returned by FromValidationErrors
+const Message = "validation failed"
+
+// FromValidationErrors returns a structured error for the validator.ValidationErrors in err's cause
+// chain, with one field per failed validation. The key of each field is the name of the struct
+// field that failed, and the value the tag that it failed:
+// 	type User struct {
+// 		Email string `validate:"required,email"`
+// 		Age   int    `validate:"gte=18"`
+// 	}
+// 	err := erreurvalidator.FromValidationErrors(validator.New().Struct(User{Email: "bob", Age: 3}))
+// gives
+// 	{"msg":"validation failed","Email":"email","Age":"gte"}
+// Fields of nested structs are keyed by their path without the name of the validated struct, e.g.
+// "Address.City". err is the cause of the returned error, so errors.As still finds the
+// validator.ValidationErrors, and Error() includes their messages. If err contains no
+// validator.ValidationErrors, it's returned as-is
+func FromValidationErrors(err error) error {
+	var ves validator.ValidationErrors
+	if !errors.As(err, &ves) {
+		return err
+	}
+
+	fields := make([]zap.Field, len(ves))
+	for i, fe := range ves {
+		fields[i] = zap.String(fieldKey(fe), fe.Tag())
+	}
+	return erreur.Wrap(err, Message, fields...)
+}
+
+// fieldKey returns the namespace of fe without the name of the top-level struct
+func fieldKey(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.IndexByte(ns, '.'); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
+}
@@ -0,0 +1,68 @@
+package erreurvalidator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+
+	"github.com/ORBAT/erreur"
+)
+
+type address struct {
+	City string `validate:"required"`
+}
+
+type user struct {
+	Email   string `validate:"required,email"`
+	Age     int    `validate:"gte=18"`
+	Name    string `validate:"required"`
+	Address address
+}
+
+func TestFromValidationErrors(t *testing.T) {
+	validationErr := validator.New().Struct(user{Email: "bob", Age: 3, Name: "Bob"})
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"direct", validationErr},
+		{"wrapped", fmt.Errorf("creating user: %w", validationErr)},
+	}
+
+	const want = `{"msg":"validation failed","Email":"email","Age":"gte","Address.City":"required"}` + "\n"
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stre, ok := erreur.AsStructured(FromValidationErrors(tt.err))
+			if !ok {
+				t.Fatal("didn't get a structured error")
+			}
+			if got := stre.JSON(); got != want {
+				t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+			}
+			var ves validator.ValidationErrors
+			if !errors.As(stre, &ves) || len(ves) != 3 {
+				t.Errorf("errors.As didn't find the validation errors in %v", stre)
+			}
+			if !strings.HasSuffix(stre.Error(), validationErr.Error()) {
+				t.Errorf("got Error() %q without the validation errors", stre.Error())
+			}
+		})
+	}
+}
+
+func TestFromValidationErrors_other(t *testing.T) {
+	plain := erreur.String("plain")
+	if got := FromValidationErrors(plain); got != plain {
+		t.Errorf("got %v, want the error as-is", got)
+	}
+	if got := FromValidationErrors(nil); got != nil {
+		t.Errorf("got %v, want nil", got)
+	}
+	if got := FromValidationErrors(validator.New().Struct(user{Email: "bob@example.com", Age: 20, Name: "Bob", Address: address{City: "Oslo"}})); got != nil {
+		t.Errorf("got %v for a valid struct, want nil", got)
+	}
+}
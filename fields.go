@@ -0,0 +1,284 @@
+package erreur
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// PrefixFields returns a copy of s where the key of every field of s is prefixed with prefix, so
+// e.g. a "host" field with the prefix "db." becomes "db.host". Only the fields of s itself are
+// affected, fields in the cause chain are left as-is
+func (s Structured) PrefixFields(prefix string) Structured {
+	fs := make([]zap.Field, len(s.fields))
+	for i, f := range s.fields {
+		if f.Type != zapcore.SkipType {
+			f.Key = prefix + f.Key
+		}
+		fs[i] = f
+	}
+	s.fields = fs
+	return s
+}
+
+// SetField returns a copy of s where field replaces the field of s with the same key, or where field
+// is appended to the fields of s if there is no such field. If s has several fields with the key,
+// the first one is replaced and the rest are removed. Fields in the cause chain are not affected
+func (s Structured) SetField(field zap.Field) Structured {
+	fs := make([]zap.Field, 0, len(s.fields)+1)
+	replaced := false
+	for _, f := range s.fields {
+		if f.Key != field.Key || f.Type == zapcore.SkipType {
+			fs = append(fs, f)
+			continue
+		}
+		if !replaced {
+			fs = append(fs, field)
+			replaced = true
+		}
+	}
+	if !replaced {
+		fs = append(fs, field)
+	}
+	s.fields = fs
+	return s
+}
+
+// fieldObject is a zapcore.ObjectMarshaler that adds its fields to the object being encoded, for
+// nesting fields under a key
+type fieldObject []zapcore.Field
+
+// MarshalLogObject implements zapcore.ObjectMarshaler
+func (fo fieldObject) MarshalLogObject(oe zapcore.ObjectEncoder) error {
+	for _, f := range fo {
+		f.AddTo(oe)
+	}
+	return nil
+}
+
+// CopyFieldsFrom returns a copy of s with the fields of the structured error in src appended to the
+// fields of s. Only the top-level fields of that error are copied, not the fields of its causes. If
+// src contains no structured error, s is returned as-is
+func (s Structured) CopyFieldsFrom(src error) Structured {
+	srcStre, ok := AsStructured(src)
+	if !ok || len(srcStre.fields) == 0 {
+		return s
+	}
+
+	fs := make([]zap.Field, 0, len(s.fields)+len(srcStre.fields))
+	fs = append(fs, s.fields...)
+	fs = append(fs, srcStre.fields...)
+	s.fields = fs
+	return s
+}
+
+// WithFields returns a copy of s with fields appended to its fields. s itself isn't affected. The
+// fields are copied into a slice with room to spare, which the errors WithFields returns share
+// copy-on-write: calling WithFields on the returned error appends to that slice in place unless
+// some other WithFields call already has, so a chain of WithFields calls only copies the fields
+// when the slice runs out of room. When adding fields one by one in a loop, a Builder avoids even
+// that
+func (s Structured) WithFields(fields ...zap.Field) Structured {
+	if len(fields) == 0 {
+		return s
+	}
+	if s.fieldBuf.claim(s.fields, len(fields)) {
+		s.fields = append(s.fields, fields...)
+		return s
+	}
+	n := len(s.fields) + len(fields)
+	fs := make([]zap.Field, 0, 2*n)
+	fs = append(fs, s.fields...)
+	s.fields = append(fs, fields...)
+	s.fieldBuf = &fieldBuf{base: &fs[:1][0]}
+	s.fieldBuf.used.Store(int64(n))
+	return s
+}
+
+// fieldBuf tracks how much of the backing array of the fields WithFields allocated is in use, so
+// that only one of the errors sharing the array gets to append to it in place
+type fieldBuf struct {
+	base *zap.Field // the first element of the array, to tell it apart from other fields slices
+	used atomic.Int64
+}
+
+// claim returns true if n more fields can be appended to fs in place, which is the case if fs is
+// the in-use part of buf's array and there's room for n more. The space is then reserved, so every
+// other claim of it fails, even a concurrent one
+func (buf *fieldBuf) claim(fs []zap.Field, n int) bool {
+	if buf == nil || len(fs)+n > cap(fs) || &fs[:cap(fs)][0] != buf.base {
+		return false
+	}
+	return buf.used.CompareAndSwap(int64(len(fs)), int64(len(fs)+n))
+}
+
+// WithFieldIf returns a copy of s with field appended to its fields if cond is true, and s as-is if
+// it's false
+func (s Structured) WithFieldIf(cond bool, field zap.Field) Structured {
+	if !cond {
+		return s
+	}
+	fs := make([]zap.Field, 0, len(s.fields)+1)
+	fs = append(fs, s.fields...)
+	s.fields = append(fs, field)
+	return s
+}
+
+// FieldIf returns field if cond is true, and a no-op field if it's false. Handy in the variadic
+// constructors:
+// 	erreur.New("request failed", erreur.FieldIf(userID != "", zap.String("userID", userID)))
+func FieldIf(cond bool, field zap.Field) zap.Field {
+	if !cond {
+		return zap.Skip()
+	}
+	return field
+}
+
+// field returns the first field of s with the given key. Fields in the cause chain aren't searched
+func (s Structured) field(key string) (zapcore.Field, bool) {
+	for _, f := range s.fields {
+		if f.Key == key && f.Type != zapcore.SkipType {
+			return f, true
+		}
+	}
+	return zapcore.Field{}, false
+}
+
+// IntField returns the value of the field with the given key if it's a signed integer field of any
+// size, e.g. one created with zap.Int or zap.Int32. Like the other typed field accessors, it only
+// looks at the fields of s itself, not those of its causes, and returns ok=false if there is no such
+// field or if it has a different type
+func (s Structured) IntField(key string) (v int64, ok bool) {
+	f, ok := s.field(key)
+	if !ok {
+		return 0, false
+	}
+	switch f.Type {
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		return f.Integer, true
+	}
+	return 0, false
+}
+
+// StringField returns the value of the field with the given key if it's a string field
+func (s Structured) StringField(key string) (v string, ok bool) {
+	f, ok := s.field(key)
+	if !ok || f.Type != zapcore.StringType {
+		return "", false
+	}
+	return f.String, true
+}
+
+// BoolField returns the value of the field with the given key if it's a bool field
+func (s Structured) BoolField(key string) (v bool, ok bool) {
+	f, ok := s.field(key)
+	if !ok || f.Type != zapcore.BoolType {
+		return false, false
+	}
+	return f.Integer == 1, true
+}
+
+// TimeField returns the value of the field with the given key if it's a time.Time field
+func (s Structured) TimeField(key string) (v time.Time, ok bool) {
+	f, ok := s.field(key)
+	if !ok || f.Type != zapcore.TimeType {
+		return time.Time{}, false
+	}
+	if loc, ok := f.Interface.(*time.Location); ok {
+		return time.Unix(0, f.Integer).In(loc), true
+	}
+	return time.Unix(0, f.Integer), true
+}
+
+// DurationField returns the value of the field with the given key if it's a time.Duration field
+func (s Structured) DurationField(key string) (v time.Duration, ok bool) {
+	f, ok := s.field(key)
+	if !ok || f.Type != zapcore.DurationType {
+		return 0, false
+	}
+	return time.Duration(f.Integer), true
+}
+
+// FloatField returns the value of the field with the given key if it's a float64 or float32 field
+func (s Structured) FloatField(key string) (v float64, ok bool) {
+	f, ok := s.field(key)
+	if !ok {
+		return 0, false
+	}
+	switch f.Type {
+	case zapcore.Float64Type:
+		return math.Float64frombits(uint64(f.Integer)), true
+	case zapcore.Float32Type:
+		return float64(math.Float32frombits(uint32(f.Integer))), true
+	}
+	return 0, false
+}
+
+// LazyField returns a field whose value is computed by calling fn only when the field is actually
+// serialized, for values that are expensive to compute. fn is called every time the field is
+// serialized, so an error that's logged twice calls it twice, and it should be safe to call
+// concurrently if the error might be serialized concurrently. The value is serialized like
+// zap.Reflect would serialize it
+func LazyField(key string, fn func() interface{}) zap.Field {
+	return zap.Field{Key: key, Type: zapcore.ReflectType, Interface: lazyValue(fn)}
+}
+
+type lazyValue func() interface{}
+
+// MarshalJSON implements json.Marshaler
+func (lv lazyValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(lv())
+}
+
+// String implements fmt.Stringer
+func (lv lazyValue) String() string {
+	return fmt.Sprint(lv())
+}
+
+// BinaryFieldLimit is the number of bytes BinaryField keeps of larger byte slices
+const BinaryFieldLimit = 256
+
+// BinaryField returns a field for a small piece of binary data, e.g. a corrupted header. It's
+// serialized as a base64 string, like zap.Binary is by zap's JSON encoder:
+// 	{"msg":"bad header","header":"3q2+7w=="}
+// If b is longer than BinaryFieldLimit, only the first BinaryFieldLimit bytes are kept, and the
+// field is serialized as an object with the kept bytes, the size of b and a truncation flag instead:
+// 	{"msg":"bad header","header":{"data":"3q2+7w...","size":4096,"truncated":true}}
+// Like with zap.Binary, b isn't copied, so it shouldn't be modified afterwards
+func BinaryField(key string, b []byte) zap.Field {
+	if len(b) <= BinaryFieldLimit {
+		return zap.Binary(key, b)
+	}
+	return zap.Object(key, truncatedBinary{data: b[:BinaryFieldLimit], size: len(b)})
+}
+
+// truncatedBinary is the value of a BinaryField that was truncated
+type truncatedBinary struct {
+	data []byte
+	size int
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler
+func (tb truncatedBinary) MarshalLogObject(oe zapcore.ObjectEncoder) error {
+	oe.AddBinary("data", tb.data)
+	oe.AddInt("size", tb.size)
+	oe.AddBool("truncated", true)
+	return nil
+}
+
+// FieldValue returns the value of the field with the given key in err or its cause chain, decoded
+// the way zap's encoders see it, e.g. int64 for zap.Int fields and string for zap.Stringer ones. If
+// several errors in the chain have the field, the outermost one wins. ok is false if no structured
+// error in the chain has the field
+func FieldValue(err error, key string) (v interface{}, ok bool) {
+	f, ok := chainField(err, key)
+	if !ok {
+		return nil, false
+	}
+	return fieldValue(f)
+}
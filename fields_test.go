@@ -0,0 +1,265 @@
+package erreur
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func mustStructured(t testing.TB, err error) Structured {
+	t.Helper()
+	stre, ok := AsStructured(err)
+	if !ok {
+		t.Fatalf("expected a structured error, got %T", err)
+	}
+	return stre
+}
+
+func TestStructured_PrefixFields(t *testing.T) {
+	cause := New("dial failed", zap.String("host", "example.com"))
+	orig := mustStructured(t, Wrap(cause, "connecting to db", zap.String("host", "db.local"), zap.Int("port", 5432)))
+
+	prefixed := orig.PrefixFields("db.")
+
+	const want = `{"msg":"connecting to db","db.host":"db.local","db.port":5432,"cause":{"msg":"dial failed","host":"example.com"}}` + "\n"
+	if got := prefixed.JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+
+	const wantOrig = `{"msg":"connecting to db","host":"db.local","port":5432,"cause":{"msg":"dial failed","host":"example.com"}}` + "\n"
+	if got := orig.JSON(); got != wantOrig {
+		t.Errorf("original was modified, got JSON\n%s\nwant\n%s", got, wantOrig)
+	}
+}
+
+func TestStructured_SetField(t *testing.T) {
+	orig := mustStructured(t, New("login failed", zap.String("user", "bob"), zap.String("password", "hunter2")))
+
+	tests := []struct {
+		name  string
+		field zap.Field
+		want  string
+	}{
+		{"replace", zap.String("password", "[redacted]"), `{"msg":"login failed","user":"bob","password":"[redacted]"}`},
+		{"replace with different type", zap.Int("user", 1234), `{"msg":"login failed","user":1234,"password":"hunter2"}`},
+		{"append", zap.Int("attempts", 3), `{"msg":"login failed","user":"bob","password":"hunter2","attempts":3}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := orig.SetField(tt.field).JSON(); got != tt.want+"\n" {
+				t.Errorf("got JSON\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	const wantOrig = `{"msg":"login failed","user":"bob","password":"hunter2"}` + "\n"
+	if got := orig.JSON(); got != wantOrig {
+		t.Errorf("original was modified, got JSON\n%s\nwant\n%s", got, wantOrig)
+	}
+}
+
+func TestStructured_SetField_duplicates(t *testing.T) {
+	orig := mustStructured(t, New("dup", zap.Int("n", 1), zap.String("x", "y"), zap.Int("n", 2)))
+
+	const want = `{"msg":"dup","n":3,"x":"y"}` + "\n"
+	if got := orig.SetField(zap.Int("n", 3)).JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestStructured_CopyFieldsFrom(t *testing.T) {
+	src := Wrap(New("deep", zap.Int("deep", 1)), "query failed", zap.String("table", "users"), zap.Int("rows", 0))
+	srcJSON := mustStructured(t, src).JSON()
+	dst := mustStructured(t, New("user not found", zap.Int("userID", 1234)))
+
+	const want = `{"msg":"user not found","userID":1234,"table":"users","rows":0}` + "\n"
+	if got := dst.CopyFieldsFrom(src).JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+
+	if got := mustStructured(t, src).JSON(); got != srcJSON {
+		t.Errorf("source was modified, got JSON\n%s\nwant\n%s", got, srcJSON)
+	}
+}
+
+func TestStructured_CopyFieldsFrom_plain(t *testing.T) {
+	dst := mustStructured(t, New("user not found", zap.Int("userID", 1234)))
+
+	for _, src := range []error{nil, String("plain")} {
+		if got, want := dst.CopyFieldsFrom(src).JSON(), dst.JSON(); got != want {
+			t.Errorf("CopyFieldsFrom(%v) got JSON\n%s\nwant\n%s", src, got, want)
+		}
+	}
+}
+
+func TestStructured_WithFieldIf(t *testing.T) {
+	orig := mustStructured(t, New("request failed", zap.Int("status", 500)))
+
+	const wantTrue = `{"msg":"request failed","status":500,"userID":"bob"}` + "\n"
+	if got := orig.WithFieldIf(true, zap.String("userID", "bob")).JSON(); got != wantTrue {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, wantTrue)
+	}
+
+	const wantFalse = `{"msg":"request failed","status":500}` + "\n"
+	if got := orig.WithFieldIf(false, zap.String("userID", "bob")).JSON(); got != wantFalse {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, wantFalse)
+	}
+	if got := orig.JSON(); got != wantFalse {
+		t.Errorf("original was modified, got JSON\n%s\nwant\n%s", got, wantFalse)
+	}
+}
+
+func TestFieldIf(t *testing.T) {
+	err := New("request failed",
+		FieldIf(true, zap.String("userID", "bob")),
+		FieldIf(false, zap.String("password", "hunter2")),
+		zap.Int("status", 500))
+
+	const want = `{"msg":"request failed","userID":"bob","status":500}` + "\n"
+	if got := mustStructured(t, err).JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+
+	if f := FieldIf(false, zap.String("password", "hunter2")); !f.Equals(zap.Skip()) {
+		t.Errorf("FieldIf(false) returned %v, want zap.Skip()", f)
+	}
+}
+
+func TestStructured_typedFields(t *testing.T) {
+	deadline := time.Date(2019, 7, 1, 12, 30, 0, 0, time.UTC)
+	stre := mustStructured(t, Wrap(New("inner", zap.Int("inner", 1)), "request timed out",
+		zap.Int32("attempt", 3),
+		zap.String("user", "bob"),
+		zap.Bool("retryable", true),
+		zap.Time("deadline", deadline),
+		zap.Duration("elapsed", 2*time.Second),
+		zap.Float32("load", 0.5),
+	))
+
+	if v, ok := stre.IntField("attempt"); !ok || v != 3 {
+		t.Errorf("IntField returned %v, %v", v, ok)
+	}
+	if v, ok := stre.StringField("user"); !ok || v != "bob" {
+		t.Errorf("StringField returned %v, %v", v, ok)
+	}
+	if v, ok := stre.BoolField("retryable"); !ok || !v {
+		t.Errorf("BoolField returned %v, %v", v, ok)
+	}
+	if v, ok := stre.TimeField("deadline"); !ok || !v.Equal(deadline) {
+		t.Errorf("TimeField returned %v, %v", v, ok)
+	}
+	if v, ok := stre.DurationField("elapsed"); !ok || v != 2*time.Second {
+		t.Errorf("DurationField returned %v, %v", v, ok)
+	}
+	if v, ok := stre.FloatField("load"); !ok || v != 0.5 {
+		t.Errorf("FloatField returned %v, %v", v, ok)
+	}
+
+	t.Run("wrong type", func(t *testing.T) {
+		if _, ok := stre.IntField("user"); ok {
+			t.Error("IntField returned ok for a string field")
+		}
+		if _, ok := stre.StringField("attempt"); ok {
+			t.Error("StringField returned ok for an int field")
+		}
+		if _, ok := stre.BoolField("user"); ok {
+			t.Error("BoolField returned ok for a string field")
+		}
+		if _, ok := stre.TimeField("elapsed"); ok {
+			t.Error("TimeField returned ok for a duration field")
+		}
+		if _, ok := stre.DurationField("deadline"); ok {
+			t.Error("DurationField returned ok for a time field")
+		}
+		if _, ok := stre.FloatField("attempt"); ok {
+			t.Error("FloatField returned ok for an int field")
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		if _, ok := stre.IntField("missing"); ok {
+			t.Error("IntField returned ok for a missing field")
+		}
+		if _, ok := stre.IntField("inner"); ok {
+			t.Error("IntField returned ok for a field of the cause")
+		}
+		if _, ok := stre.StringField("missing"); ok {
+			t.Error("StringField returned ok for a missing field")
+		}
+	})
+}
+
+func TestLazyField(t *testing.T) {
+	calls := 0
+	err := New("cache miss", LazyField("stats", func() interface{} {
+		calls++
+		return map[string]int{"hits": 10, "misses": 3}
+	}))
+
+	if calls != 0 {
+		t.Fatalf("fn was called %d times before serialization", calls)
+	}
+
+	const want = `{"msg":"cache miss","stats":{"hits":10,"misses":3}}` + "\n"
+	if got := mustStructured(t, err).JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+	if calls != 1 {
+		t.Errorf("fn was called %d times after serializing once, want 1", calls)
+	}
+
+	_ = mustStructured(t, err).JSON()
+	if calls != 2 {
+		t.Errorf("fn was called %d times after serializing twice, want 2", calls)
+	}
+}
+
+func TestBinaryField(t *testing.T) {
+	small := []byte{0xde, 0xad, 0xbe, 0xef}
+	const wantSmall = `{"msg":"bad header","header":"3q2+7w=="}` + "\n"
+	if got := mustStructured(t, New("bad header", BinaryField("header", small))).JSON(); got != wantSmall {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, wantSmall)
+	}
+
+	exact := bytes.Repeat([]byte{0xff}, BinaryFieldLimit)
+	wantExact := `{"msg":"bad header","header":"` + base64.StdEncoding.EncodeToString(exact) + `"}` + "\n"
+	if got := mustStructured(t, New("bad header", BinaryField("header", exact))).JSON(); got != wantExact {
+		t.Errorf("got JSON for exactly the limit\n%s\nwant\n%s", got, wantExact)
+	}
+
+	large := append(bytes.Repeat([]byte{0xff}, BinaryFieldLimit), 0x01, 0x02, 0x03)
+	wantLarge := `{"msg":"bad header","header":{"data":"` + base64.StdEncoding.EncodeToString(large[:BinaryFieldLimit]) +
+		`","size":259,"truncated":true}}` + "\n"
+	if got := mustStructured(t, New("bad header", BinaryField("header", large))).JSON(); got != wantLarge {
+		t.Errorf("got JSON for a truncated field\n%s\nwant\n%s", got, wantLarge)
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	inner := New("dial failed", zap.String("host", "db"), zap.Int("port", 5432))
+	err := fmt.Errorf("handler: %w", Wrap(inner, "query failed", zap.String("host", "replica")))
+
+	tests := []struct {
+		key    string
+		want   interface{}
+		wantOK bool
+	}{
+		{"host", "replica", true},
+		{"port", int64(5432), true},
+		{"missing", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			v, ok := FieldValue(err, tt.key)
+			if v != tt.want || ok != tt.wantOK {
+				t.Errorf("got %v, %v, want %v, %v", v, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
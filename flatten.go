@@ -0,0 +1,121 @@
+package erreur
+
+import (
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AllFields returns the fields of s and its whole cause chain as a flat slice, instead of nesting
+// causes in objects like Fields does. The fields of s keep their keys, while the message and fields
+// of the cause are prefixed with "cause.", those of its cause with "cause.cause." and so on, so
+// that they don't collide:
+// 	erreur.Wrap(erreur.New("dial failed", zap.String("host", "db")), "query failed", zap.Int("port", 5432))
+// has the fields
+// 	port=5432 cause.msg="dial failed" cause.host="db"
+// Errors in a multi-error cause are prefixed with their index, e.g. "cause.0.", and fields that
+// follow a zap.Namespace field are prefixed with the namespace, e.g. "db.host". This is handy for
+// adding the context of an error to a logger:
+// 	logger.With(stre.AllFields()...)
+func (s Structured) AllFields() []zapcore.Field {
+	return s.appendFlatFields(make([]zapcore.Field, 0, s.fieldCount()+2), "")
+}
+
+// SplitForLogging splits s into its message and the flat fields of its whole cause chain (see
+// AllFields), for the idiom of adding the context of an error to a logger scope and logging the
+// message separately:
+// 	msg, fields := stre.SplitForLogging()
+// 	logger.With(fields...).Error(msg)
+// The message is that of s alone, as returned by Message, since the messages of the causes are
+// among the fields
+func (s Structured) SplitForLogging() (msg string, fields []zapcore.Field) {
+	return serializedMessage(s.errorOrCause()), s.AllFields()
+}
+
+func (s Structured) appendFlatFields(dst []zapcore.Field, prefix string) []zapcore.Field {
+	// fields after a namespace belong to it, but a namespace field would also capture the fields of
+	// the causes, so the namespace is turned into a prefix instead
+	fieldPrefix := prefix
+	for _, f := range s.fields {
+		switch f.Type {
+		case zapcore.SkipType:
+			continue
+		case zapcore.NamespaceType:
+			fieldPrefix += f.Key + "."
+			continue
+		}
+		f.Key = fieldPrefix + f.Key
+		dst = append(dst, f)
+	}
+
+	if s.stack != nil {
+		dst = append(dst, zap.String(prefix+"stacktrace", s.stack.String()))
+	}
+
+	if multi, ok := s.causer.(multiWrapper); ok {
+		if s.err != nil {
+			prefix += "cause."
+		} else {
+			prefix += "errors."
+		}
+		for i, e := range multi.Unwrap() {
+			dst = appendFlatError(dst, e, prefix+strconv.Itoa(i)+".")
+		}
+	} else if cause, ok := s.structuredCause(); ok {
+		dst = cause.appendFlatError(dst, prefix+"cause.")
+	}
+	return dst
+}
+
+// appendFlatError appends the message of s and its flattened fields to dst
+func (s Structured) appendFlatError(dst []zapcore.Field, prefix string) []zapcore.Field {
+	dst = append(dst, zap.String(prefix+nestedMessageKey, serializedMessage(s.errorOrCause())))
+	return s.appendFlatFields(dst, prefix)
+}
+
+func appendFlatError(dst []zapcore.Field, err error, prefix string) []zapcore.Field {
+	if stre, ok := liftStructured(err); ok {
+		return stre.appendFlatError(dst, prefix)
+	}
+	return append(dst, zap.String(prefix+nestedMessageKey, serializedMessage(err.Error())))
+}
+
+// FieldsOf is the flat counterpart of Field: it returns the whole of err as flat fields prefixed with
+// "error.", with the message under "error.msg", the fields of the error under "error.<key>" and its
+// cause under "error.cause.<key>" like in AllFields:
+// 	logger.Error("failed to load data", erreur.FieldsOf(err)...)
+// logs
+// 	{"msg":"failed to load data","error.msg":"query failed","error.port":5432,"error.cause.msg":"dial failed"}
+// Like in Field, the messages of non-structured wrappers around a structured error are kept, e.g.
+// fmt.Errorf("handler: %w", stre) has "handler" as error.msg and stre under "error.cause.". If err
+// isn't structured and has no structured error in its chain, only its message is returned. Returns
+// nil if err is nil
+func FieldsOf(err error) []zapcore.Field {
+	if err == nil {
+		return nil
+	}
+	const prefix = "error."
+	if stre, ok := liftStructured(err); ok {
+		return stre.appendFlatError(make([]zapcore.Field, 0, stre.fieldCount()+3), prefix)
+	}
+	return []zapcore.Field{zap.String(prefix+nestedMessageKey, serializedMessage(err.Error()))}
+}
+
+// AppendFields appends Field(err) to dst and returns the extended slice, for log call sites that
+// build up their fields in a slice:
+// 	fields = erreur.AppendFields(fields, err)
+// 	logger.Error("request failed", fields...)
+// Use AppendFlatFields to append the flat fields of FieldsOf instead. If err is nil, dst is
+// returned as-is
+func AppendFields(dst []zap.Field, err error) []zap.Field {
+	if err == nil {
+		return dst
+	}
+	return append(dst, Field(err))
+}
+
+// AppendFlatFields is like AppendFields, but appends the flat fields FieldsOf returns
+func AppendFlatFields(dst []zap.Field, err error) []zap.Field {
+	return append(dst, FieldsOf(err)...)
+}
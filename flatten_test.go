@@ -0,0 +1,190 @@
+package erreur
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// fieldMap encodes fs into a map
+func fieldMap(fs []zapcore.Field) map[string]interface{} {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fs {
+		f.AddTo(enc)
+	}
+	return enc.Fields
+}
+
+func fieldKeys(fs []zapcore.Field) []string {
+	keys := make([]string, len(fs))
+	for i, f := range fs {
+		keys[i] = f.Key
+	}
+	return keys
+}
+
+func TestStructured_AllFields(t *testing.T) {
+	root := New("dial failed", zap.String("host", "db"), zap.Skip())
+	err := mustStructured(t, Wrap(Wrap(root, "query failed", zap.String("host", "replica")), "request failed", zap.Int("status", 500)))
+
+	fs := err.AllFields()
+
+	wantKeys := []string{"status", "cause.msg", "cause.host", "cause.cause.msg", "cause.cause.host"}
+	if got := fieldKeys(fs); !equalStrings(got, wantKeys) {
+		t.Errorf("got keys %v, want %v", got, wantKeys)
+	}
+
+	m := fieldMap(fs)
+	if m["cause.host"] != "replica" || m["cause.cause.host"] != "db" || m["cause.cause.msg"] != "dial failed" {
+		t.Errorf("got unexpected values %v", m)
+	}
+
+	if got := fieldKeys(err.Fields()); !equalStrings(got, []string{"status", "cause"}) {
+		t.Errorf("AllFields modified the fields of the error: %v", got)
+	}
+}
+
+func TestStructured_AllFields_multi(t *testing.T) {
+	wrapped := mustStructured(t, Wrap(errors.Join(New("a", zap.Int("n", 1)), String("b")), "both failed"))
+	wantKeys := []string{"cause.0.msg", "cause.0.n", "cause.1.msg"}
+	if got := fieldKeys(wrapped.AllFields()); !equalStrings(got, wantKeys) {
+		t.Errorf("got keys %v, want %v", got, wantKeys)
+	}
+
+	combined := mustStructured(t, Combine(New("a", zap.Int("n", 1)), String("b")))
+	wantKeys = []string{"errors.0.msg", "errors.0.n", "errors.1.msg"}
+	if got := fieldKeys(combined.AllFields()); !equalStrings(got, wantKeys) {
+		t.Errorf("got keys %v, want %v", got, wantKeys)
+	}
+}
+
+func TestStructured_AllFields_fmtWrapped(t *testing.T) {
+	err := mustStructured(t, Wrap(fmt.Errorf("layer: %w", New("dial", zap.String("host", "db"))), "q"))
+
+	wantKeys := []string{"cause.msg", "cause.cause.msg", "cause.cause.host"}
+	if got := fieldKeys(err.AllFields()); !equalStrings(got, wantKeys) {
+		t.Errorf("got keys %v, want %v", got, wantKeys)
+	}
+	m := fieldMap(err.AllFields())
+	if m["cause.msg"] != "layer" || m["cause.cause.msg"] != "dial" || m["cause.cause.host"] != "db" {
+		t.Errorf("got unexpected values %v", m)
+	}
+
+	joined := mustStructured(t, Wrap(errors.Join(fmt.Errorf("layer: %w", New("a", zap.Int("n", 1))), String("b")), "both failed"))
+	wantKeys = []string{"cause.0.msg", "cause.0.cause.msg", "cause.0.cause.n", "cause.1.msg"}
+	if got := fieldKeys(joined.AllFields()); !equalStrings(got, wantKeys) {
+		t.Errorf("got keys %v for a joined cause, want %v", got, wantKeys)
+	}
+
+	_, fs := err.SplitForLogging()
+	if got := fieldKeys(fs); !equalStrings(got, []string{"cause.msg", "cause.cause.msg", "cause.cause.host"}) {
+		t.Errorf("SplitForLogging returned keys %v", got)
+	}
+}
+
+func TestStructured_AllFields_namespace(t *testing.T) {
+	root := New("dial failed", zap.Namespace("conn"), zap.String("host", "db"))
+	err := mustStructured(t, Wrap(root, "query failed", zap.Int("status", 500), zap.Namespace("db"), zap.String("table", "users"), zap.Namespace("opts"), zap.Bool("ro", true)))
+
+	wantKeys := []string{"status", "db.table", "db.opts.ro", "cause.msg", "cause.conn.host"}
+	if got := fieldKeys(err.AllFields()); !equalStrings(got, wantKeys) {
+		t.Errorf("got keys %v, want %v", got, wantKeys)
+	}
+}
+
+func TestStructured_SplitForLogging(t *testing.T) {
+	root := New("dial failed", zap.String("host", "db"))
+	err := mustStructured(t, Wrap(root, "query failed", zap.Int("port", 5432)))
+
+	msg, fs := err.SplitForLogging()
+	if msg != "query failed" {
+		t.Errorf("got message %q, want %q", msg, "query failed")
+	}
+	wantKeys := []string{"port", "cause.msg", "cause.host"}
+	if got := fieldKeys(fs); !equalStrings(got, wantKeys) {
+		t.Errorf("got keys %v, want %v", got, wantKeys)
+	}
+	if m := fieldMap(fs); m["port"] != int64(5432) || m["cause.msg"] != "dial failed" {
+		t.Errorf("got unexpected values %v", m)
+	}
+
+	msg, fs = mustStructured(t, Structure(String("EOF"), zap.Int("n", 3))).SplitForLogging()
+	if msg != "EOF" || !equalStrings(fieldKeys(fs), []string{"n"}) {
+		t.Errorf("got %q and keys %v for a Structure'd error, want %q and [n]", msg, fieldKeys(fs), "EOF")
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFieldsOf(t *testing.T) {
+	root := New("dial failed", zap.String("host", "db"))
+	err := Wrap(root, "query failed", zap.Int("port", 5432))
+
+	tests := []struct {
+		name     string
+		err      error
+		wantKeys []string
+	}{
+		{"structured", err, []string{"error.msg", "error.port", "error.cause.msg", "error.cause.host"}},
+		{"fmt wrapped", fmt.Errorf("handler: %w", err), []string{"error.msg", "error.cause.msg", "error.cause.port", "error.cause.cause.msg", "error.cause.cause.host"}},
+		{"plain", String("plain"), []string{"error.msg"}},
+		{"nil", nil, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldKeys(FieldsOf(tt.err)); !equalStrings(got, tt.wantKeys) {
+				t.Errorf("got keys %v, want %v", got, tt.wantKeys)
+			}
+		})
+	}
+
+	m := fieldMap(FieldsOf(err))
+	if m["error.msg"] != "query failed" || m["error.port"] != int64(5432) || m["error.cause.msg"] != "dial failed" || m["error.cause.host"] != "db" {
+		t.Errorf("got fields %v", m)
+	}
+	if m := fieldMap(FieldsOf(fmt.Errorf("handler: %w", err))); m["error.msg"] != "handler" || m["error.cause.msg"] != "query failed" {
+		t.Errorf("got fields %v for a fmt.Errorf wrapped error", m)
+	}
+	if FieldsOf(nil) != nil {
+		t.Error("FieldsOf(nil) returned non-nil")
+	}
+}
+
+func TestAppendFields(t *testing.T) {
+	err := Wrap(New("dial failed"), "query failed", zap.Int("port", 5432))
+	base := []zap.Field{zap.String("user", "bob")}
+
+	fs := AppendFields(base[:1:1], err)
+	if got := fieldKeys(fs); !equalStrings(got, []string{"user", "error"}) {
+		t.Errorf("got keys %v", got)
+	}
+	if !fs[1].Equals(Field(err)) {
+		t.Errorf("got field %v, want Field(err)", fs[1])
+	}
+
+	flat := AppendFlatFields(base[:1:1], err)
+	if got := fieldKeys(flat); !equalStrings(got, []string{"user", "error.msg", "error.port", "error.cause.msg"}) {
+		t.Errorf("got flat keys %v", got)
+	}
+
+	if got := AppendFields(base, nil); len(got) != 1 {
+		t.Errorf("got %v for a nil error, want dst as-is", got)
+	}
+	if got := AppendFlatFields(base, nil); len(got) != 1 {
+		t.Errorf("got %v for a nil error with AppendFlatFields, want dst as-is", got)
+	}
+}
@@ -0,0 +1,44 @@
+package erreur
+
+import (
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+)
+
+var bufPool = buffer.NewPool()
+
+const hexDigits = "0123456789abcdef"
+
+// appendHTMLEscaped appends the JSON in src to buf, escaping characters that are unsafe in HTML the
+// same way as json.HTMLEscape
+func appendHTMLEscaped(buf *buffer.Buffer, src []byte) {
+	start := 0
+	for i := 0; i < len(src); {
+		c := src[i]
+		if c == '<' || c == '>' || c == '&' {
+			buf.Write(src[start:i])
+			buf.AppendString(`\u00`)
+			buf.AppendByte(hexDigits[c>>4])
+			buf.AppendByte(hexDigits[c&0xF])
+			i++
+			start = i
+			continue
+		}
+		if c < utf8.RuneSelf {
+			i++
+			continue
+		}
+		// U+2028 is LINE SEPARATOR and U+2029 is PARAGRAPH SEPARATOR, encoded as E2 80 A8 and E2 80 A9
+		if c == 0xE2 && i+2 < len(src) && src[i+1] == 0x80 && src[i+2]&^1 == 0xA8 {
+			buf.Write(src[start:i])
+			buf.AppendString(`\u202`)
+			buf.AppendByte(hexDigits[src[i+2]&0xF])
+			i += 3
+			start = i
+			continue
+		}
+		i++
+	}
+	buf.Write(src[start:])
+}
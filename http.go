@@ -0,0 +1,138 @@
+package erreur
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AllowedHTTPHeaders lists the request headers that WithHTTPRequest includes in errors. Headers
+// that aren't listed are never included, so that sensitive headers like Authorization or Cookie
+// don't leak into logs. Header names are matched case-insensitively.
+var AllowedHTTPHeaders = []string{"Accept", "Content-Type", "User-Agent"}
+
+// WithHTTPRequest returns a copy of s with a summary of r added as fields: the request method under
+// "method", the URL path under "path", and the values of any headers in AllowedHTTPHeaders under
+// "headers". Multiple values for the same header are joined with ", "
+func (s Structured) WithHTTPRequest(r *http.Request) Structured {
+	fs := make([]zap.Field, 0, len(s.fields)+3)
+	fs = append(fs, s.fields...)
+	fs = append(fs, zap.String("method", r.Method), zap.String("path", r.URL.Path))
+
+	var headers fieldObject
+	for _, name := range AllowedHTTPHeaders {
+		if values := r.Header.Values(name); len(values) > 0 {
+			headers = append(headers, zap.String(http.CanonicalHeaderKey(name), strings.Join(values, ", ")))
+		}
+	}
+	if len(headers) > 0 {
+		fs = append(fs, zap.Object("headers", headers))
+	}
+
+	s.fields = fs
+	return s
+}
+
+// responseWrittenKey is the key of the flag set by WithResponseWritten
+const responseWrittenKey = "responseWritten"
+
+// WithResponseWritten returns a copy of s flagged, under the "responseWritten" key, as having
+// happened after the HTTP response was already (partially) written. Error handling middleware can
+// check the flag with ResponseWritten to avoid trying to write an error response on top of it
+func (s Structured) WithResponseWritten() Structured {
+	return s.SetField(zap.Bool(responseWrittenKey, true))
+}
+
+// ResponseWritten returns true if err or an error in its cause chain was flagged with
+// WithResponseWritten
+func ResponseWritten(err error) bool {
+	f, ok := chainField(err, responseWrittenKey)
+	return ok && f.Type == zapcore.BoolType && f.Integer == 1
+}
+
+// problemEncConf is the encoder config for ProblemJSON, which has no message key since the message
+// is the "title" member
+var problemEncConf zapcore.EncoderConfig
+
+func init() {
+	problemEncConf = jsonEncConf
+	problemEncConf.MessageKey = ""
+}
+
+// ProblemJSON returns s as an RFC 7807 application/problem+json document for API error responses:
+// 	{"type":"about:blank","title":"user not found","status":404,"detail":"user not found","userID":1234}
+// The title is the message of s, the status is the one set with WithHTTPStatus (or 500 if there is
+// none), and the detail is the message of s as it's serialized (see SetStripControlChars and
+// SetEmptyMessagePlaceholder). The fields of s are added as extension members, except for ones that
+// would clash with the standard members or the "httpStatus" field that the status comes from. The
+// messages and fields of the causes of s aren't included, as they tend to be internal details
+func (s Structured) ProblemJSON() []byte {
+	status, ok := HTTPStatusOf(s)
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	fs := make([]zapcore.Field, 0, len(s.fields)+4)
+	fs = append(fs,
+		zap.String("type", "about:blank"),
+		zap.String("title", s.Message()),
+		zap.Int("status", status),
+		zap.String("detail", serializedMessage(s.errorOrCause())),
+	)
+	for _, f := range s.appendOwnFields(nil) {
+		switch f.Key {
+		case "type", "title", "status", "detail", "instance", httpStatusKey:
+			continue
+		}
+		fs = append(fs, f)
+	}
+
+	buf, _ := zapcore.NewJSONEncoder(problemEncConf).EncodeEntry(zapcore.Entry{}, fs)
+	return copyAndFree(buf)
+}
+
+// RecoveryMiddleware returns HTTP middleware that recovers panics in the handlers it wraps. The
+// panic is turned into a structured error with FromPanic, so it has the call stack of the panic,
+// and the request is added to it with WithHTTPRequest. The panic value is serialized as its cause
+// even if it isn't a structured error. The error is logged with logger under the
+// "error" key (see Field), and the client gets a 500 Internal Server Error response. If the handler
+// already started writing its response, the 500 can't replace it, and the client gets whatever was
+// written so far.
+//
+// Panics with http.ErrAbortHandler aren't recovered, since that's how handlers abort a response
+// on purpose
+func RecoveryMiddleware(logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				err := FromPanic(panicCause(v)).(Structured).WithHTTPRequest(r)
+				logger.Error("recovered from a panic in an HTTP handler", Field(err))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// panicCause returns the panic value v as a structured error, so that it, unlike a plain cause, is
+// serialized as the cause of the error FromPanic returns
+func panicCause(v interface{}) error {
+	cause, ok := v.(error)
+	if !ok {
+		cause = String(fmt.Sprint(v))
+	}
+	if stre, ok := liftStructured(cause); ok {
+		return stre
+	}
+	return Structure(cause)
+}
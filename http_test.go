@@ -0,0 +1,173 @@
+package erreur
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestStructured_WithHTTPRequest(t *testing.T) {
+	r := httptest.NewRequest("POST", "/users/1234?debug=1", nil)
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Add("Accept", "application/json")
+	r.Header.Add("Accept", "text/plain")
+	r.Header.Set("Authorization", "Bearer hunter2")
+	r.Header.Set("Cookie", "session=hunter2")
+
+	stre := mustStructured(t, New("invalid user", zap.Int("userID", 1234))).WithHTTPRequest(r)
+
+	const want = `{"msg":"invalid user","userID":1234,"method":"POST","path":"/users/1234",` +
+		`"headers":{"Accept":"application/json, text/plain","Content-Type":"application/json"}}` + "\n"
+	if got := stre.JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestStructured_WithHTTPRequest_allowlist(t *testing.T) {
+	defer func(orig []string) { AllowedHTTPHeaders = orig }(AllowedHTTPHeaders)
+	AllowedHTTPHeaders = []string{"x-request-id"}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Request-Id", "abc")
+	r.Header.Set("User-Agent", "curl")
+
+	const want = `{"msg":"oops","method":"GET","path":"/","headers":{"X-Request-Id":"abc"}}` + "\n"
+	if got := mustStructured(t, New("oops")).WithHTTPRequest(r).JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+
+	r.Header.Del("X-Request-Id")
+	const wantNoHeaders = `{"msg":"oops","method":"GET","path":"/"}` + "\n"
+	if got := mustStructured(t, New("oops")).WithHTTPRequest(r).JSON(); got != wantNoHeaders {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, wantNoHeaders)
+	}
+}
+
+func TestStructured_ProblemJSON(t *testing.T) {
+	notFound := mustStructured(t, Wrap(String("no rows"), "user not found", zap.Int("userID", 1234))).
+		WithHTTPStatus(http.StatusNotFound)
+
+	tests := []struct {
+		name string
+		s    Structured
+		want string
+	}{
+		{"with status", notFound,
+			`{"type":"about:blank","title":"user not found","status":404,"detail":"user not found","userID":1234}`},
+		{"default status", mustStructured(t, New("database unavailable", zap.String("db", "users"))),
+			`{"type":"about:blank","title":"database unavailable","status":500,"detail":"database unavailable","db":"users"}`},
+		{"clashing fields", mustStructured(t, New("bad input", zap.String("title", "x"), zap.String("type", "y"), zap.Int("field", 1))),
+			`{"type":"about:blank","title":"bad input","status":500,"detail":"bad input","field":1}`},
+		{"causes left out", mustStructured(t, Wrap(notFound, "loading profile")).WithHTTPStatus(http.StatusBadGateway),
+			`{"type":"about:blank","title":"loading profile","status":502,"detail":"loading profile"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.s.ProblemJSON()
+			if string(got) != tt.want+"\n" {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+			var doc map[string]interface{}
+			if err := json.Unmarshal(got, &doc); err != nil {
+				t.Errorf("output isn't valid JSON: %v", err)
+			}
+		})
+	}
+
+	IncludeFieldsInError = true
+	defer func() { IncludeFieldsInError = false }()
+	const want = `{"type":"about:blank","title":"user not found","status":404,"detail":"user not found","userID":1234}` + "\n"
+	if got := string(notFound.ProblemJSON()); got != want {
+		t.Errorf("got\n%s\nwith IncludeFieldsInError, want\n%s", got, want)
+	}
+}
+
+func TestResponseWritten(t *testing.T) {
+	written := mustStructured(t, New("client went away", zap.Int("bytesWritten", 512))).WithResponseWritten()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"own", written, true},
+		{"wrapped", Wrap(written, "streaming results"), true},
+		{"fmt wrapped", fmt.Errorf("handler: %w", Wrap(written, "streaming results")), true},
+		{"set twice", mustStructured(t, Wrap(written, "outer")).WithResponseWritten(), true},
+		{"unflagged", New("query failed"), false},
+		{"plain", String("plain"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResponseWritten(tt.err); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	const want = `{"msg":"client went away","bytesWritten":512,"responseWritten":true}` + "\n"
+	if got := written.JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	handler := RecoveryMiddleware(zap.New(obs))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		panic(fmt.Sprintf("no handler for %s", r.URL.Path))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/users/1234", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, want 500", rec.Code)
+	}
+
+	entries := logs.AllUntimed()
+	if len(entries) != 1 || entries[0].Level != zapcore.ErrorLevel {
+		t.Fatalf("got log entries %v, want a single error entry", entries)
+	}
+	err, ok := entries[0].ContextMap()["error"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("got entry %v without an error object", entries[0].ContextMap())
+	}
+	if err["msg"] != "panic" || err["method"] != "GET" || err["path"] != "/users/1234" {
+		t.Errorf("got error %v", err)
+	}
+	if cause, _ := err["cause"].(map[string]interface{}); cause["msg"] != "no handler for /users/1234" {
+		t.Errorf("got cause %v", err["cause"])
+	}
+	if st, _ := err["stacktrace"].(string); !strings.Contains(st, "TestRecoveryMiddleware") {
+		t.Errorf("the stack trace doesn't include the panicking handler:\n%s", st)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/ok", nil))
+	if rec.Code != http.StatusNoContent || logs.Len() != 1 {
+		t.Errorf("got status %d and %d log entries without a panic", rec.Code, logs.Len())
+	}
+
+	aborting := RecoveryMiddleware(zap.New(obs))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("got panic %v, want http.ErrAbortHandler to be re-panicked", v)
+		}
+	}()
+	aborting.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
@@ -0,0 +1,66 @@
+package erreur
+
+import (
+	"sync"
+)
+
+// interned holds the Strings returned by Intern
+var interned sync.Map // string -> String
+
+// Intern returns a String error with the message s. Every call with the same message returns a
+// String that shares its storage with the first one, so messages that are created at runtime (e.g.
+// read from configuration) don't each keep their own copy, and comparing interned Strings is a
+// pointer comparison in the common case. Interned Strings compare equal to non-interned ones with
+// the same message, since String is a plain string type.
+//
+// Interned strings are never released, so Intern shouldn't be used with an unbounded number of
+// messages. It's safe for concurrent use
+func Intern(s string) String {
+	if v, ok := interned.Load(s); ok {
+		return v.(String)
+	}
+	v, _ := interned.LoadOrStore(s, String(s))
+	return v.(String)
+}
+
+// StringSet is a set of String errors, for checking whether an error is any of several sentinels
+// with one lookup per error in the chain instead of one errors.Is call per sentinel:
+// 	retryable := erreur.NewStringSet(ErrTimeout, ErrUnavailable, ErrThrottled)
+// 	if retryable.Is(err) {
+type StringSet map[String]struct{}
+
+// NewStringSet returns a StringSet with the given Strings
+func NewStringSet(ss ...String) StringSet {
+	set := make(StringSet, len(ss))
+	for _, s := range ss {
+		set[s] = struct{}{}
+	}
+	return set
+}
+
+// Is returns true if err or any error in its cause chain is a String in set. Like errors.Is, it
+// looks into all errors of multi-errors like the ones returned by errors.Join
+func (set StringSet) Is(err error) bool {
+	for err != nil {
+		if s, ok := err.(String); ok {
+			if _, found := set[s]; found {
+				return true
+			}
+		}
+
+		switch e := err.(type) {
+		case wrapper:
+			err = e.Unwrap()
+		case multiWrapper:
+			for _, inner := range e.Unwrap() {
+				if set.Is(inner) {
+					return true
+				}
+			}
+			return false
+		default:
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package erreur
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestIntern(t *testing.T) {
+	// build the messages at runtime so that they don't share storage as constants would
+	a := Intern(strings.Repeat("not found", 1))
+	b := Intern(string([]byte("not found")))
+
+	if a != b {
+		t.Fatalf("interned Strings differ: %q, %q", a, b)
+	}
+	if unsafe.StringData(string(a)) != unsafe.StringData(string(b)) {
+		t.Error("interned Strings don't share storage")
+	}
+	if a != String("not found") {
+		t.Error("interned String isn't equal to a plain String with the same message")
+	}
+	if !errors.Is(fmt.Errorf("lookup: %w", a), b) {
+		t.Error("errors.Is didn't match interned Strings")
+	}
+	if Intern("other") == a {
+		t.Error("different messages were interned as the same String")
+	}
+}
+
+func TestStringSet_Is(t *testing.T) {
+	const (
+		errTimeout     = String("timeout")
+		errUnavailable = String("unavailable")
+		errNotFound    = String("not found")
+	)
+	retryable := NewStringSet(errTimeout, errUnavailable)
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"direct", errTimeout, true},
+		{"structured chain", Wrap(fmt.Errorf("dial: %w", Wrap(errUnavailable, "query failed")), "request failed"), true},
+		{"joined", errors.Join(errNotFound, Wrap(errTimeout, "second")), true},
+		{"combined", Combine(errNotFound, errUnavailable), true},
+		{"equal message", String("timeout"), true},
+		{"interned", Intern("unavailable"), true},
+		{"not in set", Wrap(errNotFound, "lookup failed"), false},
+		{"joined not in set", errors.Join(errNotFound, String("other")), false},
+		{"same message, different type", errors.New("timeout"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable.Is(tt.err); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkStringSet_Is(b *testing.B) {
+	sentinels := make([]String, 20)
+	for i := range sentinels {
+		sentinels[i] = Intern(fmt.Sprintf("sentinel %d", i))
+	}
+	set := NewStringSet(sentinels...)
+	err := Wrap(fmt.Errorf("layer: %w", sentinels[len(sentinels)-1]), "failed")
+
+	b.Run("StringSet.Is", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			benchFound = set.Is(err)
+		}
+	})
+
+	b.Run("errors.Is", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			found := false
+			for _, s := range sentinels {
+				if errors.Is(err, s) {
+					found = true
+					break
+				}
+			}
+			benchFound = found
+		}
+	})
+}
+
+func BenchmarkIntern(b *testing.B) {
+	msg := string([]byte("not found"))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = Intern(msg)
+	}
+}
@@ -0,0 +1,43 @@
+package erreur
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// JSONRPCInternalError is the JSON-RPC 2.0 "Internal error" code, which JSONRPCError uses for
+// errors without an integer code
+const JSONRPCInternalError = -32603
+
+// JSONRPCError returns s as a JSON-RPC 2.0 error object for RPC error responses:
+// 	{"code":-32001,"message":"user not found","data":{"userID":1234}}
+// The code is the one CodeOf returns for s if it's an integer, either from an integer "code" field
+// or a string code in decimal form, and JSONRPCInternalError otherwise. The message is the message
+// of s, and the fields of s other than "code" are the members of "data", which is left out if there
+// are none. Like with ProblemJSON, the fields of the causes of s aren't included
+func (s Structured) JSONRPCError() json.RawMessage {
+	code := JSONRPCInternalError
+	if c, ok := CodeOf(s); ok {
+		if n, err := strconv.Atoi(c); err == nil {
+			code = n
+		}
+	}
+
+	var data fieldObject
+	for _, f := range s.appendOwnFields(nil) {
+		if f.Key != codeKey {
+			data = append(data, f)
+		}
+	}
+
+	fs := []zapcore.Field{zap.Int("code", code), zap.String("message", s.Message())}
+	if len(data) > 0 {
+		fs = append(fs, zap.Object("data", data))
+	}
+
+	buf, _ := zapcore.NewJSONEncoder(problemEncConf).EncodeEntry(zapcore.Entry{}, fs)
+	return copyAndFree(buf)
+}
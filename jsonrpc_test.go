@@ -0,0 +1,51 @@
+package erreur
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestStructured_JSONRPCError(t *testing.T) {
+	notFound := mustStructured(t, New("user not found", zap.Int("code", -32001), zap.Int("userID", 1234)))
+
+	tests := []struct {
+		name string
+		s    Structured
+		want string
+	}{
+		{"integer code", notFound,
+			`{"code":-32001,"message":"user not found","data":{"userID":1234}}`},
+		{"decimal string code", mustStructured(t, New("rate limited")).WithCode("429"),
+			`{"code":429,"message":"rate limited"}`},
+		{"non-integer code", mustStructured(t, New("bad input", zap.String("param", "id"))).WithCode("INVALID"),
+			`{"code":-32603,"message":"bad input","data":{"param":"id"}}`},
+		{"no code", mustStructured(t, New("database unavailable")),
+			`{"code":-32603,"message":"database unavailable"}`},
+		{"code from cause", mustStructured(t, Wrap(notFound, "loading profile", zap.String("profile", "main"))),
+			`{"code":-32001,"message":"loading profile","data":{"profile":"main"}}`},
+		{"namespace", mustStructured(t, New("query failed", zap.Namespace("db"), zap.String("table", "users"))),
+			`{"code":-32603,"message":"query failed","data":{"db":{"table":"users"}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.s.JSONRPCError()
+			if string(got) != tt.want+"\n" {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+			var obj struct {
+				Code    *int                   `json:"code"`
+				Message *string                `json:"message"`
+				Data    map[string]interface{} `json:"data"`
+			}
+			if err := json.Unmarshal(got, &obj); err != nil {
+				t.Fatalf("output isn't valid JSON: %v", err)
+			}
+			if obj.Code == nil || obj.Message == nil {
+				t.Errorf("output is missing code or message: %s", got)
+			}
+		})
+	}
+}
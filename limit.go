@@ -0,0 +1,351 @@
+package erreur
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// truncatedKey is the key of the marker added to JSON output truncated because of
+// SetMaxOutputBytes
+const truncatedKey = "truncated"
+
+// truncatedSuffix ends JSON output truncated because of SetMaxOutputBytes
+const truncatedSuffix = `,"` + truncatedKey + `":true}` + "\n"
+
+// encodeLimited encodes an entry with msg and fields like encodeEntry does, but without ever writing
+// more than maxOutputBytes bytes of output. Fields are kept in order up to (but not including) the
+// first one that doesn't fit, and they're followed by a "truncated" marker if any were left out. If
+// even msg doesn't fit, it's shortened
+func encodeLimited(msg string, fields []zapcore.Field) *buffer.Buffer {
+	// the output can't be smaller than an object with an empty message and the marker
+	max := maxOutputBytes
+	if min := len(`{"`+jsonEncConf.MessageKey+`":""`) + len(truncatedSuffix); max < min {
+		max = min
+	}
+
+	le := limitedEncoder{buf: make([]byte, 0, max), limit: max}
+	le.appendRaw(`{`)
+	le.addKey(jsonEncConf.MessageKey)
+	start := len(le.buf)
+
+	// without fields after the message, only the message has to fit, without a marker
+	le.limit = max - len("}\n")
+	le.appendString(msg)
+	if len(fields) > 0 || le.full {
+		le.buf, le.full = le.buf[:start], false
+		le.limit = max - len(truncatedSuffix)
+		le.appendString(msg)
+	}
+
+	truncated := le.full
+	le.full = false
+	for i, f := range fields {
+		if truncated {
+			break
+		}
+		if i == len(fields)-1 {
+			le.limit = max - len("}\n")
+		}
+		start, namespaces := len(le.buf), le.openNamespaces
+		f.AddTo(&le)
+		if le.full {
+			le.buf, le.openNamespaces, le.full = le.buf[:start], namespaces, false
+			truncated = true
+		}
+	}
+
+	// no more writes can fail, since there's room for what's left
+	le.limit = math.MaxInt
+	for ; le.openNamespaces > 0; le.openNamespaces-- {
+		le.appendRaw(`}`)
+	}
+	if truncated {
+		le.appendRaw(truncatedSuffix)
+	} else {
+		le.appendRaw("}\n")
+	}
+
+	buf := bufPool.Get()
+	_, _ = buf.Write(le.buf)
+	return buf
+}
+
+// limitedEncoder is a zapcore.ObjectEncoder that produces the same JSON zap's JSON encoder does,
+// except that it won't write more than limit bytes. A value that doesn't fit sets full, after which
+// nothing more is written
+type limitedEncoder struct {
+	buf            []byte
+	limit          int
+	full           bool
+	openNamespaces int
+}
+
+// appendRaw appends s to the output as-is, apart from HTML escaping it if SetHTMLEscape is on
+func (le *limitedEncoder) appendRaw(s string) {
+	if le.full {
+		return
+	}
+	if htmlEscape && strings.ContainsAny(s, "<>&\u2028\u2029") {
+		escaped := bufPool.Get()
+		appendHTMLEscaped(escaped, []byte(s))
+		s = escaped.String()
+		escaped.Free()
+	}
+	if len(le.buf)+len(s) > le.limit {
+		le.full = true
+		return
+	}
+	le.buf = append(le.buf, s...)
+}
+
+// appendString appends s as a quoted JSON string, escaped like zap's JSON encoder does. As much of s
+// as fits is written rune by rune, so that a string that's cut short is still valid JSON, and the
+// closing quote is reserved so that it's always written
+func (le *limitedEncoder) appendString(s string) {
+	le.addElementSeparator()
+	le.appendRaw(`"`)
+	opened := !le.full
+	le.limit--
+	var esc [6]byte
+	for i := 0; i < len(s) && !le.full; {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			le.appendRaw(`\ufffd`)
+		case r >= utf8.RuneSelf:
+			le.appendRaw(s[i : i+size])
+		case r >= 0x20 && r != '\\' && r != '"':
+			le.appendRaw(s[i : i+1])
+		case r == '\\' || r == '"':
+			le.appendRaw(string([]byte{'\\', byte(r)}))
+		case r == '\n':
+			le.appendRaw(`\n`)
+		case r == '\r':
+			le.appendRaw(`\r`)
+		case r == '\t':
+			le.appendRaw(`\t`)
+		default:
+			esc = [6]byte{'\\', 'u', '0', '0', hexDigits[r>>4], hexDigits[r&0xF]}
+			le.appendRaw(string(esc[:]))
+		}
+		i += size
+	}
+	le.limit++
+	if opened {
+		full := le.full
+		le.full = false
+		le.appendRaw(`"`)
+		le.full = full
+	}
+}
+
+func (le *limitedEncoder) addElementSeparator() {
+	if len(le.buf) == 0 {
+		return
+	}
+	switch le.buf[len(le.buf)-1] {
+	case '{', '[', ':', ',':
+	default:
+		le.appendRaw(`,`)
+	}
+}
+
+func (le *limitedEncoder) addKey(key string) {
+	le.appendString(key)
+	le.appendRaw(`:`)
+}
+
+func (le *limitedEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	le.addKey(key)
+	return le.AppendArray(arr)
+}
+
+func (le *limitedEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	le.addKey(key)
+	return le.AppendObject(obj)
+}
+
+func (le *limitedEncoder) AddBinary(key string, val []byte) {
+	le.AddString(key, base64.StdEncoding.EncodeToString(val))
+}
+
+func (le *limitedEncoder) AddByteString(key string, val []byte) {
+	le.addKey(key)
+	le.AppendByteString(val)
+}
+
+func (le *limitedEncoder) AddBool(key string, val bool) {
+	le.addKey(key)
+	le.AppendBool(val)
+}
+
+func (le *limitedEncoder) AddComplex128(key string, val complex128) {
+	le.addKey(key)
+	le.AppendComplex128(val)
+}
+
+func (le *limitedEncoder) AddDuration(key string, val time.Duration) {
+	le.addKey(key)
+	le.AppendDuration(val)
+}
+
+func (le *limitedEncoder) AddFloat64(key string, val float64) {
+	le.addKey(key)
+	le.AppendFloat64(val)
+}
+
+func (le *limitedEncoder) AddInt64(key string, val int64) {
+	le.addKey(key)
+	le.AppendInt64(val)
+}
+
+func (le *limitedEncoder) AddReflected(key string, obj interface{}) error {
+	le.addKey(key)
+	return le.AppendReflected(obj)
+}
+
+func (le *limitedEncoder) OpenNamespace(key string) {
+	le.addKey(key)
+	le.appendRaw(`{`)
+	le.openNamespaces++
+}
+
+func (le *limitedEncoder) AddString(key, val string) {
+	le.addKey(key)
+	le.AppendString(val)
+}
+
+func (le *limitedEncoder) AddTime(key string, val time.Time) {
+	le.addKey(key)
+	le.AppendTime(val)
+}
+
+func (le *limitedEncoder) AddUint64(key string, val uint64) {
+	le.addKey(key)
+	le.AppendUint64(val)
+}
+
+func (le *limitedEncoder) AppendArray(arr zapcore.ArrayMarshaler) error {
+	le.addElementSeparator()
+	le.appendRaw(`[`)
+	err := arr.MarshalLogArray(le)
+	le.appendRaw(`]`)
+	return err
+}
+
+func (le *limitedEncoder) AppendObject(obj zapcore.ObjectMarshaler) error {
+	le.addElementSeparator()
+	le.appendRaw(`{`)
+	err := obj.MarshalLogObject(le)
+	le.appendRaw(`}`)
+	return err
+}
+
+func (le *limitedEncoder) AppendBool(val bool) {
+	le.addElementSeparator()
+	le.appendRaw(strconv.FormatBool(val))
+}
+
+func (le *limitedEncoder) AppendByteString(val []byte) {
+	le.AppendString(string(val))
+}
+
+func (le *limitedEncoder) AppendComplex128(val complex128) {
+	le.addElementSeparator()
+	r, i := real(val), imag(val)
+	le.appendRaw(`"` + strconv.FormatFloat(r, 'f', -1, 64) + "+" + strconv.FormatFloat(i, 'f', -1, 64) + `i"`)
+}
+
+func (le *limitedEncoder) AppendDuration(val time.Duration) {
+	cur := len(le.buf)
+	jsonEncConf.EncodeDuration(val, le)
+	if cur == len(le.buf) && !le.full {
+		le.AppendInt64(int64(val))
+	}
+}
+
+func (le *limitedEncoder) AppendInt64(val int64) {
+	le.addElementSeparator()
+	le.appendRaw(strconv.FormatInt(val, 10))
+}
+
+// AppendReflected implements zapcore.ArrayEncoder. The value is marshaled with encoding/json, which
+// can't stop partway, so unlike the rest of the output it's held in memory in full
+func (le *limitedEncoder) AppendReflected(val interface{}) error {
+	if le.full {
+		return nil
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(val); err != nil {
+		return err
+	}
+	le.addElementSeparator()
+	le.appendRaw(string(bytes.TrimSuffix(b.Bytes(), []byte("\n"))))
+	return nil
+}
+
+func (le *limitedEncoder) AppendString(val string) {
+	le.appendString(val)
+}
+
+func (le *limitedEncoder) AppendTime(val time.Time) {
+	cur := len(le.buf)
+	jsonEncConf.EncodeTime(val, le)
+	if cur == len(le.buf) && !le.full {
+		le.AppendInt64(val.UnixNano())
+	}
+}
+
+func (le *limitedEncoder) AppendUint64(val uint64) {
+	le.addElementSeparator()
+	le.appendRaw(strconv.FormatUint(val, 10))
+}
+
+func (le *limitedEncoder) appendFloat(val float64, bitSize int) {
+	le.addElementSeparator()
+	switch {
+	case math.IsNaN(val):
+		le.appendRaw(`"NaN"`)
+	case math.IsInf(val, 1):
+		le.appendRaw(`"+Inf"`)
+	case math.IsInf(val, -1):
+		le.appendRaw(`"-Inf"`)
+	default:
+		le.appendRaw(strconv.FormatFloat(val, 'f', -1, bitSize))
+	}
+}
+
+func (le *limitedEncoder) AddComplex64(k string, v complex64) { le.AddComplex128(k, complex128(v)) }
+func (le *limitedEncoder) AddFloat32(k string, v float32)     { le.AddFloat64(k, float64(v)) }
+func (le *limitedEncoder) AddInt(k string, v int)             { le.AddInt64(k, int64(v)) }
+func (le *limitedEncoder) AddInt32(k string, v int32)         { le.AddInt64(k, int64(v)) }
+func (le *limitedEncoder) AddInt16(k string, v int16)         { le.AddInt64(k, int64(v)) }
+func (le *limitedEncoder) AddInt8(k string, v int8)           { le.AddInt64(k, int64(v)) }
+func (le *limitedEncoder) AddUint(k string, v uint)           { le.AddUint64(k, uint64(v)) }
+func (le *limitedEncoder) AddUint32(k string, v uint32)       { le.AddUint64(k, uint64(v)) }
+func (le *limitedEncoder) AddUint16(k string, v uint16)       { le.AddUint64(k, uint64(v)) }
+func (le *limitedEncoder) AddUint8(k string, v uint8)         { le.AddUint64(k, uint64(v)) }
+func (le *limitedEncoder) AddUintptr(k string, v uintptr)     { le.AddUint64(k, uint64(v)) }
+func (le *limitedEncoder) AppendComplex64(v complex64)        { le.AppendComplex128(complex128(v)) }
+func (le *limitedEncoder) AppendFloat64(v float64)            { le.appendFloat(v, 64) }
+func (le *limitedEncoder) AppendFloat32(v float32)            { le.appendFloat(float64(v), 32) }
+func (le *limitedEncoder) AppendInt(v int)                    { le.AppendInt64(int64(v)) }
+func (le *limitedEncoder) AppendInt32(v int32)                { le.AppendInt64(int64(v)) }
+func (le *limitedEncoder) AppendInt16(v int16)                { le.AppendInt64(int64(v)) }
+func (le *limitedEncoder) AppendInt8(v int8)                  { le.AppendInt64(int64(v)) }
+func (le *limitedEncoder) AppendUint(v uint)                  { le.AppendUint64(uint64(v)) }
+func (le *limitedEncoder) AppendUint32(v uint32)              { le.AppendUint64(uint64(v)) }
+func (le *limitedEncoder) AppendUint16(v uint16)              { le.AppendUint64(uint64(v)) }
+func (le *limitedEncoder) AppendUint8(v uint8)                { le.AppendUint64(uint64(v)) }
+func (le *limitedEncoder) AppendUintptr(v uintptr)            { le.AppendUint64(uint64(v)) }
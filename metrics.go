@@ -0,0 +1,37 @@
+package erreur
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// Metrics returns the total number of fields in s and its cause chain, and the length in bytes of
+// the JSON serialization of s. It's meant for finding out which errors are expensive to log, e.g.
+// when profiling. Skipped fields and the "cause" and "stacktrace" fields added during serialization
+// aren't counted
+func (s Structured) Metrics() (fieldCount int, serializedBytes int) {
+	buf := s.JSONBuffer()
+	serializedBytes = buf.Len()
+	buf.Free()
+	return s.fieldCount(), serializedBytes
+}
+
+func (s Structured) fieldCount() int {
+	n := 0
+	for _, f := range s.fields {
+		if f.Type != zapcore.SkipType {
+			n++
+		}
+	}
+
+	switch cause := s.causer.(type) {
+	case Structured:
+		n += cause.fieldCount()
+	case multiWrapper:
+		for _, e := range cause.Unwrap() {
+			if stre, ok := e.(Structured); ok {
+				n += stre.fieldCount()
+			}
+		}
+	}
+	return n
+}
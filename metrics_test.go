@@ -0,0 +1,37 @@
+package erreur
+
+import (
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestStructured_Metrics(t *testing.T) {
+	leaf := New("connection error", zap.Int("code", 1234), zap.String("addr", "example.com"), zap.Skip())
+	nested := Wrap(Wrap(leaf, "loading failed", zap.String("table", "users")), "request failed")
+	joined := Wrap(errors.Join(leaf, String("plain")), "both failed", zap.Int("n", 2))
+
+	tests := []struct {
+		name       string
+		err        error
+		wantFields int
+	}{
+		{"leaf", leaf, 2},
+		{"nested", nested, 3},
+		{"joined", joined, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stre := mustStructured(t, tt.err)
+			fieldCount, serializedBytes := stre.Metrics()
+			if fieldCount != tt.wantFields {
+				t.Errorf("got %d fields, want %d", fieldCount, tt.wantFields)
+			}
+			if want := len(stre.JSON()); serializedBytes != want {
+				t.Errorf("got %d bytes, want %d", serializedBytes, want)
+			}
+		})
+	}
+}
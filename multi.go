@@ -0,0 +1,317 @@
+package erreur
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Combine returns an error that holds both a and b as siblings, for reporting two independent
+// errors together. Unlike with Wrap, neither error is the cause of the other. The returned error's
+// Error() is the messages of a and b joined with "; ", and it serializes as
+// 	{"msg":"<a's message>; <b's message>","errors":[<a>,<b>]}
+// where structured errors are serialized as usual, and plain errors as objects with just a message.
+// errors.Is and errors.As look at both errors.
+//
+// If one of the errors is nil, the other one is returned as-is. Returns nil if both are nil
+func Combine(a, b error) error {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	return Structured{causer: combined{a, b}}
+}
+
+// combined is the cause of errors created with Combine and JoinDedup
+type combined []error
+
+// Error implements the error interface
+func (c combined) Error() string {
+	var sb strings.Builder
+	for i, e := range c {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(e.Error())
+	}
+	return sb.String()
+}
+
+// Unwrap returns the combined errors
+func (c combined) Unwrap() []error {
+	return c
+}
+
+// multiWrapper is implemented by errors that wrap several errors, like the ones returned by
+// errors.Join or fmt.Errorf with multiple %w verbs
+type multiWrapper interface {
+	Unwrap() []error
+}
+
+// multiCause is the serialized form of a multi-error cause: an object with an "errors" array
+type multiCause []error
+
+// MarshalLogObject implements zapcore.ObjectMarshaler
+func (mc multiCause) MarshalLogObject(oe zapcore.ObjectEncoder) error {
+	return oe.AddArray("errors", errorArray(mc))
+}
+
+// errorArray serializes structured errors as objects, and plain errors as objects with just a
+// message
+type errorArray []error
+
+// MarshalLogArray implements zapcore.ArrayMarshaler
+func (ea errorArray) MarshalLogArray(ae zapcore.ArrayEncoder) error {
+	for _, e := range ea {
+		if e == nil {
+			continue
+		}
+		if stre, ok := e.(Structured); ok {
+			if err := ae.AppendObject(stre); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := ae.AppendObject(plainError{e}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// plainError serializes a non-structured error as an object with just a message
+type plainError struct {
+	err error
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler
+func (pe plainError) MarshalLogObject(oe zapcore.ObjectEncoder) error {
+	oe.AddString(nestedMessageKey, serializedMessage(pe.err.Error()))
+	return nil
+}
+
+// JoinDedup returns an error with the given message that has errs as its cause, like Wrap with an
+// errors.Join cause, but with duplicates removed: errors that are Equal to an error that came
+// before them are dropped, and the first one gets a "count" field with the number of times it
+// occurred. This way an error that happened N times is logged once instead of N times:
+// 	{"msg":"sync failed","cause":{"errors":[{"msg":"timeout","count":3},{"msg":"disk full"}]}}
+// Error() joins the messages of the deduplicated errors with "; ". Nil errors are ignored, and nil
+// is returned if there are no non-nil errors
+func JoinDedup(message string, errs ...error) error {
+	uniq := make([]error, 0, len(errs))
+	counts := make([]int, 0, len(errs))
+
+outer:
+	for _, e := range errs {
+		if e == nil {
+			continue
+		}
+		for i, u := range uniq {
+			if Equal(u, e) {
+				counts[i]++
+				continue outer
+			}
+		}
+		uniq = append(uniq, e)
+		counts = append(counts, 1)
+	}
+
+	if len(uniq) == 0 {
+		return nil
+	}
+
+	for i, e := range uniq {
+		if counts[i] == 1 {
+			continue
+		}
+		count := zap.Int("count", counts[i])
+		if stre, ok := e.(Structured); ok {
+			uniq[i] = stre.SetField(count)
+		} else {
+			uniq[i] = Structure(e, count)
+		}
+	}
+
+	return Structured{err: String(message), causer: combined(uniq)}
+}
+
+// Equal returns true if a and b are equal errors. Structured errors are equal if they have the
+// same message, equal fields in the same order, and Equal causes. Skipped fields are ignored.
+// Other errors are equal if they have the same type and the same message. Two nil errors are equal
+func Equal(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	sa, aIsStructured := a.(Structured)
+	sb, bIsStructured := b.(Structured)
+	if aIsStructured != bIsStructured {
+		return false
+	}
+	if !aIsStructured {
+		return reflect.TypeOf(a) == reflect.TypeOf(b) && a.Error() == b.Error()
+	}
+
+	if (sa.err == nil) != (sb.err == nil) || (sa.err != nil && sa.err.Error() != sb.err.Error()) {
+		return false
+	}
+	if !fieldsEqual(sa.fields, sb.fields) {
+		return false
+	}
+	return Equal(sa.causer, sb.causer)
+}
+
+// Canonical returns a deterministic string form of s and its cause chain that's the same for errors
+// with the same messages and field values regardless of the order of the fields, so it can be used
+// as a map key for grouping identical errors. It's a JSON object with the fields sorted by key:
+// 	{"cause":{"msg":"dial failed"},"fields":{"host":"db","port":5432},"msg":"query failed"}
+// Field values are compared the way zap's encoders see them, like with Matches. Stack traces and
+// the "ts" and "goID" fields recorded by SetRecordTime and SetRecordGoroutineID are left out, since
+// they differ between otherwise identical errors. The format isn't meant to be parsed, and may
+// change between versions of erreur, so it shouldn't be stored
+func (s Structured) Canonical() string {
+	b, err := json.Marshal(canonicalValue(s))
+	if err != nil {
+		// some zap.Reflect value can't be marshaled
+		return fmt.Sprintf("%v", canonicalValue(s))
+	}
+	return string(b)
+}
+
+// canonicalValue returns err as a value with maps instead of ordered fields
+func canonicalValue(err error) map[string]interface{} {
+	stre, ok := liftStructured(err)
+	if !ok {
+		return map[string]interface{}{"msg": err.Error()}
+	}
+
+	v := map[string]interface{}{"msg": stre.errorOrCause()}
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range stre.fields {
+		if f.Type == zapcore.SkipType || f.Key == timeKey || f.Key == goIDKey {
+			continue
+		}
+		f.AddTo(enc)
+	}
+	if len(enc.Fields) > 0 {
+		v["fields"] = enc.Fields
+	}
+
+	switch cause := stre.causer.(type) {
+	case nil:
+	case multiWrapper:
+		var errs []interface{}
+		for _, e := range cause.Unwrap() {
+			if e != nil {
+				errs = append(errs, canonicalValue(e))
+			}
+		}
+		v["errors"] = errs
+	default:
+		// an error created with Structure already has the message of a plain cause as its own
+		if c, ok := stre.structuredCause(); ok {
+			v["cause"] = canonicalValue(c)
+		} else if stre.err != nil {
+			v["cause"] = canonicalValue(cause)
+		}
+	}
+	return v
+}
+
+// fieldsEqual returns true if a and b have equal fields in the same order, ignoring skipped fields
+func fieldsEqual(a, b []zapcore.Field) bool {
+	i, j := 0, 0
+	for {
+		for i < len(a) && a[i].Type == zapcore.SkipType {
+			i++
+		}
+		for j < len(b) && b[j].Type == zapcore.SkipType {
+			j++
+		}
+		if i == len(a) || j == len(b) {
+			return i == len(a) && j == len(b)
+		}
+		if !a[i].Equals(b[j]) {
+			return false
+		}
+		i++
+		j++
+	}
+}
+
+// Matches returns true if err or an error in its cause chain has at least the message and fields
+// of subset, so err can have fields subset doesn't have. This is handy for assertions that only
+// care about some of the fields of an error:
+// 	want, _ := erreur.AsStructured(erreur.New("query failed", zap.String("table", "users")))
+// 	if !erreur.Matches(err, want) {
+// If subset has a structured cause, the cause chain of the matching error has to match that cause
+// the same way, and if it has some other cause, the chain has to have an error with the same
+// message. Field values are compared the way zap's encoders see them, so e.g. zap.Int and zap.Int64
+// fields with the same value match, and skipped fields are ignored
+func Matches(err error, subset Structured) bool {
+	for err != nil {
+		if stre, ok := err.(Structured); ok && matchesLevel(stre, subset) {
+			switch cause := subset.causer.(type) {
+			case Structured:
+				if Matches(stre.causer, cause) {
+					return true
+				}
+			case nil:
+				return true
+			default:
+				if hasMessage(stre.causer, cause.Error()) {
+					return true
+				}
+			}
+		}
+		cause, ok := err.(wrapper)
+		if !ok {
+			break
+		}
+		err = cause.Unwrap()
+	}
+	return false
+}
+
+// matchesLevel returns true if s has the message of subset (if subset has one of its own), and
+// every field of subset
+func matchesLevel(s, subset Structured) bool {
+	if subset.err != nil && (s.err == nil || s.err.Error() != subset.err.Error()) {
+		return false
+	}
+	for _, want := range subset.fields {
+		wantV, ok := fieldValue(want)
+		if !ok {
+			continue
+		}
+		f, ok := s.field(want.Key)
+		if !ok {
+			return false
+		}
+		if v, ok := fieldValue(f); !ok || !reflect.DeepEqual(v, wantV) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasMessage returns true if err or an error in its cause chain has msg as its Error()
+func hasMessage(err error, msg string) bool {
+	for err != nil {
+		if err.Error() == msg {
+			return true
+		}
+		cause, ok := err.(wrapper)
+		if !ok {
+			return false
+		}
+		err = cause.Unwrap()
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+package erreur
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestStructured_Fields_joinedCause(t *testing.T) {
+	a := New("disk full", zap.String("disk", "/dev/sda"))
+	b := String("network unreachable")
+	err := mustStructured(t, Wrap(errors.Join(a, b), "flushing failed", zap.Int("attempt", 2)))
+
+	const want = `{"msg":"flushing failed","attempt":2,"cause":{"errors":[` +
+		`{"msg":"disk full","disk":"/dev/sda"},{"msg":"network unreachable"}]}}` + "\n"
+	if got := err.JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+
+	if !errors.Is(err, b) {
+		t.Error("errors.Is didn't find an error in the joined cause")
+	}
+}
+
+func TestStructured_Fields_nestedJoinedCause(t *testing.T) {
+	inner := Wrap(errors.Join(String("a"), String("b")), "inner")
+	err := mustStructured(t, Wrap(inner, "outer"))
+
+	const want = `{"msg":"outer","cause":{"msg":"inner","cause":{"errors":[{"msg":"a"},{"msg":"b"}]}}}` + "\n"
+	if got := err.JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestStructure_joinedCause(t *testing.T) {
+	err := mustStructured(t, Structure(errors.Join(String("a"), New("b", zap.Int("n", 1))), zap.Int("count", 2)))
+
+	const want = `{"msg":"a\nb","count":2,"errors":[{"msg":"a"},{"msg":"b","n":1}]}` + "\n"
+	if got := err.JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCombine(t *testing.T) {
+	const plain = String("cache unavailable")
+	db := New("db unavailable", zap.String("host", "db.local"))
+	err := Combine(db, plain)
+
+	if got, want := err.Error(), "db unavailable; cache unavailable"; got != want {
+		t.Errorf("got Error() %q, want %q", got, want)
+	}
+
+	const want = `{"msg":"db unavailable; cache unavailable","errors":[` +
+		`{"msg":"db unavailable","host":"db.local"},{"msg":"cache unavailable"}]}` + "\n"
+	if got := mustStructured(t, err).JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+
+	const wantLog = `{"msg":"failed to load data","error":{"msg":"db unavailable; cache unavailable","errors":[` +
+		`{"msg":"db unavailable","host":"db.local"},{"msg":"cache unavailable"}]}}` + "\n"
+	if got := logLine(err); got != wantLog {
+		t.Errorf("got log line\n%s\nwant\n%s", got, wantLog)
+	}
+
+	if !errors.Is(err, plain) {
+		t.Error("errors.Is didn't find the plain error")
+	}
+}
+
+func TestCombine_nil(t *testing.T) {
+	a := String("a")
+	if got := Combine(a, nil); got != a {
+		t.Errorf("Combine(a, nil) returned %v", got)
+	}
+	if got := Combine(nil, a); got != a {
+		t.Errorf("Combine(nil, a) returned %v", got)
+	}
+	if got := Combine(nil, nil); got != nil {
+		t.Errorf("Combine(nil, nil) returned %v", got)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	leaf := func() error { return New("timeout", zap.String("host", "a"), zap.Int("ms", 100)) }
+
+	tests := []struct {
+		name string
+		a, b error
+		want bool
+	}{
+		{"same", leaf(), leaf(), true},
+		{"skip ignored", leaf(), New("timeout", zap.String("host", "a"), zap.Skip(), zap.Int("ms", 100)), true},
+		{"different message", leaf(), New("timed out", zap.String("host", "a"), zap.Int("ms", 100)), false},
+		{"different field value", leaf(), New("timeout", zap.String("host", "b"), zap.Int("ms", 100)), false},
+		{"missing field", leaf(), New("timeout", zap.String("host", "a")), false},
+		{"same chain", Wrap(leaf(), "sync"), Wrap(leaf(), "sync"), true},
+		{"different cause", Wrap(leaf(), "sync"), Wrap(String("timeout"), "sync"), false},
+		{"wrap vs structure", Structure(String("timeout")), Wrap(String("timeout"), "timeout"), false},
+		{"plain", String("x"), String("x"), true},
+		{"plain different types", String("x"), errors.New("x"), false},
+		{"structured vs plain", New("x"), String("x"), false},
+		{"nil", nil, nil, true},
+		{"one nil", leaf(), nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Equal(tt.a, tt.b); got != tt.want {
+				t.Errorf("got Equal(a, b) %v, want %v", got, tt.want)
+			}
+			if got := Equal(tt.b, tt.a); got != tt.want {
+				t.Errorf("got Equal(b, a) %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinDedup(t *testing.T) {
+	timeout := func() error { return New("timeout", zap.String("host", "a")) }
+	const diskFull = String("disk full")
+
+	err := JoinDedup("sync failed", timeout(), diskFull, timeout(), nil, New("timeout", zap.String("host", "b")), timeout(), diskFull)
+
+	const want = `{"msg":"sync failed","cause":{"errors":[` +
+		`{"msg":"timeout","host":"a","count":3},{"msg":"disk full","count":2},{"msg":"timeout","host":"b"}]}}` + "\n"
+	if got := mustStructured(t, err).JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+	if got, want := err.Error(), "sync failed: timeout; disk full; timeout"; got != want {
+		t.Errorf("got Error() %q, want %q", got, want)
+	}
+	if !errors.Is(err, diskFull) {
+		t.Error("errors.Is didn't find a deduplicated error")
+	}
+}
+
+func TestJoinDedup_distinct(t *testing.T) {
+	err := JoinDedup("sync failed", String("a"), String("b"))
+
+	const want = `{"msg":"sync failed","cause":{"errors":[{"msg":"a"},{"msg":"b"}]}}` + "\n"
+	if got := mustStructured(t, err).JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+
+	if err := JoinDedup("nothing failed", nil, nil); err != nil {
+		t.Errorf("got %v for only nil errors, want nil", err)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	actual := fmt.Errorf("handler: %w", Wrap(
+		Wrap(String("connection refused"), "dial failed", zap.String("host", "db"), zap.Int("port", 5432)),
+		"query failed", zap.String("table", "users"), zap.Int("rows", 0), zap.Duration("took", time.Second)))
+
+	tests := []struct {
+		name   string
+		subset error
+		want   bool
+	}{
+		{"message only", New("query failed"), true},
+		{"subset of fields", New("query failed", zap.String("table", "users")), true},
+		{"all fields", New("query failed", zap.String("table", "users"), zap.Int("rows", 0), zap.Duration("took", time.Second)), true},
+		{"different int type", New("query failed", zap.Int64("rows", 0)), true},
+		{"deeper level", New("dial failed", zap.Int("port", 5432)), true},
+		{"with structured cause", Wrap(New("dial failed", zap.String("host", "db")), "query failed", zap.String("table", "users")), true},
+		{"with plain cause", Wrap(String("connection refused"), "dial failed"), true},
+		{"no own message", Structure(String("connection refused"), zap.String("host", "db")), true},
+		{"skipped field", New("query failed", zap.Skip()), true},
+		{"wrong value", New("query failed", zap.String("table", "groups")), false},
+		{"missing field", New("query failed", zap.String("user", "bob")), false},
+		{"field of another level", New("query failed", zap.String("host", "db")), false},
+		{"wrong message", New("request failed"), false},
+		{"wrong cause", Wrap(New("dial failed", zap.String("host", "replica")), "query failed"), false},
+		{"wrong plain cause", Wrap(String("timeout"), "dial failed"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Matches(actual, mustStructured(t, tt.subset)); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if Matches(nil, mustStructured(t, New("query failed"))) {
+		t.Error("nil matched")
+	}
+}
+
+func TestStructured_Canonical(t *testing.T) {
+	a := mustStructured(t, Wrap(New("dial failed", zap.String("host", "db")), "query failed",
+		zap.Int("port", 5432), zap.String("table", "users"), zap.Skip()))
+	b := mustStructured(t, Wrap(New("dial failed", zap.String("host", "db")), "query failed",
+		zap.String("table", "users"), zap.Int64("port", 5432)))
+
+	const want = `{"cause":{"fields":{"host":"db"},"msg":"dial failed"},"fields":{"port":5432,"table":"users"},"msg":"query failed"}`
+	if got := a.Canonical(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if a.Canonical() != b.Canonical() {
+		t.Errorf("errors with reordered fields have different canonical forms:\n%s\n%s", a.Canonical(), b.Canonical())
+	}
+
+	different := mustStructured(t, Wrap(New("dial failed", zap.String("host", "replica")), "query failed",
+		zap.Int("port", 5432), zap.String("table", "users")))
+	if a.Canonical() == different.Canonical() {
+		t.Error("errors with different causes have the same canonical form")
+	}
+
+	SetRecordTime(true)
+	reset := setNow(time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC))
+	first := New("timeout", zap.Int("n", 1))
+	setNow(time.Date(2019, 7, 1, 13, 0, 0, 0, time.UTC))
+	second := New("timeout", zap.Int("n", 1))
+	reset()
+	SetRecordTime(false)
+	if mustStructured(t, first).Canonical() != mustStructured(t, second).Canonical() {
+		t.Error("the recorded creation time affected the canonical form")
+	}
+
+	combined := mustStructured(t, Combine(String("a"), New("b")))
+	if got, want := combined.Canonical(), `{"errors":[{"msg":"a"},{"msg":"b"}],"msg":"a; b"}`; got != want {
+		t.Errorf("got %s for a combined error, want %s", got, want)
+	}
+
+	fmtWrapped := func(port int) Structured {
+		return mustStructured(t, Wrap(fmt.Errorf("db: %w", New("dial", zap.Int("port", port))), "q"))
+	}
+	const wantWrapped = `{"cause":{"cause":{"fields":{"port":1},"msg":"dial"},"msg":"db"},"msg":"q"}`
+	if got := fmtWrapped(1).Canonical(); got != wantWrapped {
+		t.Errorf("got\n%s\nwant\n%s for a structured error in a non-structured wrapper", got, wantWrapped)
+	}
+	if fmtWrapped(1).Canonical() == fmtWrapped(2).Canonical() {
+		t.Error("errors with different fields below a non-structured wrapper have the same canonical form")
+	}
+	structure := mustStructured(t, Structure(fmt.Errorf("db: %w", New("dial", zap.Int("port", 1))), zap.Int("n", 1)))
+	if got, want := structure.Canonical(), `{"cause":{"cause":{"fields":{"port":1},"msg":"dial"},"msg":"db"},"fields":{"n":1},"msg":"db: dial"}`; got != want {
+		t.Errorf("got\n%s\nwant\n%s for Structure", got, want)
+	}
+}
@@ -0,0 +1,35 @@
+package erreur
+
+// Timeout returns the result of the Timeout method of the first error in the cause chain of s that
+// has one, or false if none does. Along with Temporary, this makes Structured a net.Error, so code
+// that checks for e.g. net.Error timeouts keeps working when net errors are wrapped. Structured
+// errors in the chain are skipped, since their methods only delegate to their own causes
+func (s Structured) Timeout() bool {
+	te, ok := netCause(s, func(e error) bool {
+		_, ok := e.(interface{ Timeout() bool })
+		return ok
+	}).(interface{ Timeout() bool })
+	return ok && te.Timeout()
+}
+
+// Temporary returns the result of the Temporary method of the first error in the cause chain of s
+// that has one, or false if none does. See Timeout
+func (s Structured) Temporary() bool {
+	te, ok := netCause(s, func(e error) bool {
+		_, ok := e.(interface{ Temporary() bool })
+		return ok
+	}).(interface{ Temporary() bool })
+	return ok && te.Temporary()
+}
+
+// netCause returns the first error in the cause chain of s that isn't structured and for which
+// hasMethod returns true, or nil if there's none
+func netCause(s Structured, hasMethod func(error) bool) error {
+	if s.causer == nil {
+		return nil
+	}
+	return Find(s.causer, func(e error) bool {
+		_, structured := e.(Structured)
+		return !structured && hasMethod(e)
+	})
+}
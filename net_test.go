@@ -0,0 +1,51 @@
+package erreur
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+type fakeNetError struct {
+	timeout, temporary bool
+}
+
+func (fakeNetError) Error() string     { return "fake net error" }
+func (e fakeNetError) Timeout() bool   { return e.timeout }
+func (e fakeNetError) Temporary() bool { return e.temporary }
+
+var _ net.Error = Structured{}
+
+func TestStructured_netError(t *testing.T) {
+	tests := []struct {
+		name                       string
+		err                        error
+		wantTimeout, wantTemporary bool
+	}{
+		{"timeout", Wrap(fakeNetError{timeout: true}, "dial failed"), true, false},
+		{"temporary", Wrap(fakeNetError{temporary: true}, "dial failed"), false, true},
+		{"both", Structure(fakeNetError{timeout: true, temporary: true}, zap.String("addr", "db")), true, true},
+		{"deep", Wrap(fmt.Errorf("layer: %w", Wrap(fakeNetError{timeout: true}, "dial failed")), "query failed"), true, false},
+		{"joined", Wrap(errors.Join(New("a"), fakeNetError{timeout: true}), "both failed"), true, false},
+		{"not a net error", Wrap(String("plain"), "failed"), false, false},
+		{"no cause", New("leaf"), false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ne net.Error
+			if !errors.As(tt.err, &ne) {
+				t.Fatal("errors.As didn't find a net.Error")
+			}
+			if got := ne.Timeout(); got != tt.wantTimeout {
+				t.Errorf("got Timeout() %v, want %v", got, tt.wantTimeout)
+			}
+			if got := ne.Temporary(); got != tt.wantTemporary {
+				t.Errorf("got Temporary() %v, want %v", got, tt.wantTemporary)
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package erreur
+
+import (
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// IncludeFieldsInError controls whether Error() includes the context fields of structured errors.
+// When false (the default), Error() returns only the error messages. When true, the fields of each
+// error in the chain are appended to its message in a compact " {key=value ...}" form, so that
+// even plain-text logging with e.g. %v carries the context:
+// 	connection error {code=1234 addr=example.com}
+var IncludeFieldsInError = false
+
+// RedactPII controls whether personally identifiable information added with e.g. WithUser is
+// redacted when structured errors are serialized or included in Error(). When true, the values are
+// replaced with a truncated SHA-256 hash like "sha256:9f86d081884c7d65". The check is done during
+// serialization, so it also affects errors created before RedactPII was set. The default is false.
+var RedactPII = false
+
+// nestedMessageKey is the key used for error messages in serialized error objects
+var nestedMessageKey = "msg"
+
+// SetNestedMessageKey sets the key used for the message of errors serialized as objects, i.e. by
+// MarshalLogObject. This includes errors logged with Field and all causes, but not the top-level
+// message in the output of JSON(), which is the encoder's entry message and always uses "msg". The
+// default is "msg", so by default error messages look the same as log messages:
+// 	{"msg":"failed to load data","error":{"msg":"connection error"}}
+// but e.g. after SetNestedMessageKey("errorMsg"):
+// 	{"msg":"failed to load data","error":{"errorMsg":"connection error"}}
+func SetNestedMessageKey(key string) {
+	nestedMessageKey = key
+}
+
+// htmlEscape controls whether JSON output is HTML-escaped
+var htmlEscape = false
+
+// SetHTMLEscape controls whether the JSON produced by JSON(), JSONBuffer() and MarshalJSON() has
+// the characters <, > and & (and the line and paragraph separators U+2028 and U+2029) escaped as
+// \u003c etc., like encoding/json does by default, so that it can be safely embedded in HTML. The
+// default is false, in which case the output is exactly what zap's JSON encoder produces.
+func SetHTMLEscape(escape bool) {
+	htmlEscape = escape
+}
+
+// typedFields controls whether fields are serialized with their types
+var typedFields = false
+
+// SetTypedFields controls whether the fields of structured errors are serialized as objects that
+// contain both the value and the type of the field, for consumers that need to know the exact type
+// to parse a value correctly. With typed fields on, the fields of
+// 	erreur.New("connection error", zap.Int("code", 1234), zap.String("addr", "example.com"))
+// are serialized as
+// 	{"msg":"connection error","code":{"value":1234,"type":"int64"},"addr":{"value":"example.com","type":"string"}}
+// The type names are the same as the ones DebugString uses. The "cause" and "stacktrace" fields
+// added by erreur are not affected. The default is false.
+func SetTypedFields(typed bool) {
+	typedFields = typed
+}
+
+// groupByDotPrefix controls whether fields with dotted keys are grouped into objects
+var groupByDotPrefix = false
+
+// SetGroupByDotPrefix controls whether the fields of structured errors that have a dotted key are
+// grouped into nested objects when serialized. With grouping on, the fields of
+// 	erreur.New("connection error", zap.String("db.host", "db.local"), zap.Int("db.port", 5432))
+// are serialized as
+// 	{"msg":"connection error","db":{"host":"db.local","port":5432}}
+// instead of using the keys as-is. Keys with several dots are grouped into objects nested several
+// levels deep, and each group is placed where its first field is. A field whose key is the same as
+// a group's prefix (e.g. "db" along with "db.host") isn't merged into the group, so the output
+// would have the key twice. The grouping is done separately for every error in the cause chain. The
+// default is false.
+func SetGroupByDotPrefix(group bool) {
+	groupByDotPrefix = group
+}
+
+// emptyMessagePlaceholder is serialized instead of empty error messages
+var emptyMessagePlaceholder = ""
+
+// SetEmptyMessagePlaceholder sets the message that's serialized in place of an empty error message,
+// e.g. for a third-party error with an empty Error() string that was wrapped with Structure. With
+// SetEmptyMessagePlaceholder("<empty>"), such an error is serialized as
+// 	{"msg":"<empty>","user":"bob"}
+// instead of having an empty "msg". This affects the messages of causes too, but not the output of
+// Error(). The default is an empty string, i.e. empty messages are serialized as-is.
+func SetEmptyMessagePlaceholder(placeholder string) {
+	emptyMessagePlaceholder = placeholder
+}
+
+// serializedMessage returns msg as it should be serialized: with control characters stripped if
+// SetStripControlChars is on, and replaced with the placeholder set with SetEmptyMessagePlaceholder
+// if it's empty
+func serializedMessage(msg string) string {
+	if stripControlChars {
+		msg = stripControl(msg)
+	}
+	if msg == "" {
+		return emptyMessagePlaceholder
+	}
+	return msg
+}
+
+// stripControlChars controls whether control characters are stripped from messages
+var stripControlChars = false
+
+// SetStripControlChars controls whether control characters are stripped from error messages, for
+// messages from external systems that contain e.g. newlines that break line-based log parsing.
+// zap's JSON encoder escapes control characters so the JSON stays valid, but Error() returns them
+// as-is, so plain-text logs of the error would be split over several lines. When on, newlines,
+// carriage returns and tabs are replaced with spaces, and other control characters (including the
+// C1 ones and DEL) are removed, both in Error() and in the serialized messages of the error and its
+// causes. Field values are left as-is. The default is false.
+func SetStripControlChars(strip bool) {
+	stripControlChars = strip
+}
+
+// stripControl returns s with newlines, carriage returns and tabs replaced with spaces and other
+// control characters removed
+func stripControl(s string) string {
+	if strings.IndexFunc(s, unicode.IsControl) < 0 {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			return ' '
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// errorSeparator is what Error() puts between the message of an error and its cause
+var errorSeparator = ": "
+
+// SetErrorSeparator sets the string Error() puts between the message of a structured error and the
+// message of its cause. The default is ": ", like with fmt.Errorf, so that e.g. after
+// SetErrorSeparator(" -> ")
+// 	erreur.Wrap(erreur.Wrap(erreur.New("disk full"), "write failed"), "flush failed")
+// returns "flush failed -> write failed -> disk full" instead of "flush failed: write failed: disk
+// full". Messages added by non-structured wrappers like fmt.Errorf("context: %w", err) and by Errorf
+// still use the separators in their format strings, and serialized errors aren't affected since
+// their messages are serialized separately.
+func SetErrorSeparator(sep string) {
+	errorSeparator = sep
+}
+
+// chainAsArray controls whether causes are serialized as a "chain" array
+var chainAsArray = false
+
+// SetChainAsArray controls whether the causes of structured errors are serialized as a flat "chain"
+// array with one object per cause, outermost first, instead of as nested "cause" objects. Each
+// object has the message and fields of one error but not its cause. With the option on,
+// 	erreur.Wrap(erreur.Wrap(erreur.New("disk full"), "write failed"), "flush failed")
+// is serialized as
+// 	{"msg":"flush failed","chain":[{"msg":"write failed"},{"msg":"disk full"}]}
+// instead of
+// 	{"msg":"flush failed","cause":{"msg":"write failed","cause":{"msg":"disk full"}}}
+// The errors of a multi-error cause are still serialized in the object of the error whose cause it
+// is. The default is false.
+func SetChainAsArray(asArray bool) {
+	chainAsArray = asArray
+}
+
+// plainCauseAsObject controls whether plain causes are serialized
+var plainCauseAsObject = false
+
+// SetPlainCauseAsObject controls whether causes that aren't structured are serialized. By default
+// only structured causes are, since the message of a plain cause is already part of Error(), so
+// 	erreur.Wrap(io.EOF, "reading header failed", zap.Int("offset", 42))
+// is serialized as
+// 	{"msg":"reading header failed","offset":42}
+// With SetPlainCauseAsObject(true), plain causes are serialized as objects with just a message
+// under "cause", like structured causes are, so consumers can rely on the same shape for both:
+// 	{"msg":"reading header failed","offset":42,"cause":{"msg":"EOF"}}
+// Errors created with Structure aren't affected, as their message is already that of the cause.
+func SetPlainCauseAsObject(asObject bool) {
+	plainCauseAsObject = asObject
+}
+
+// timeKey is the key of the creation time field added when recordTime is set
+const timeKey = "ts"
+
+// recordTime controls whether constructors record the creation time
+var recordTime = false
+
+// SetRecordTime controls whether New, Wrap and the other constructors that create an error with
+// its own message record the time the error was created. The time is added as a zap.Time field
+// under the "ts" key, so it's serialized like other fields:
+// 	{"msg":"connection error","code":1234,"ts":1561984200.5}
+// and it can be read back with Structured.Time. Errors created with Structure aren't timestamped,
+// as they only add fields to an existing error. The default is false.
+func SetRecordTime(record bool) {
+	recordTime = record
+}
+
+// goIDKey is the key of the goroutine ID field added when recordGoroutineID is set
+const goIDKey = "goID"
+
+// recordGoroutineID controls whether constructors record the ID of the creating goroutine
+var recordGoroutineID = false
+
+// SetRecordGoroutineID controls whether New, Wrap and the other constructors that record the
+// creation time with SetRecordTime also record the ID of the goroutine that created the error, for
+// debugging concurrency issues. The ID is added as a number under the "goID" key:
+// 	{"msg":"connection error","goID":42}
+// and matches the IDs in goroutine dumps and panic stack traces.
+//
+// Go doesn't expose goroutine IDs, so the ID is parsed from the header line of the current
+// goroutine's stack trace ("goroutine 42 [running]:"), as returned by runtime.Stack. That's slow
+// compared to creating an error, and it relies on the format of the stack trace, which isn't
+// guaranteed to stay the same; if the ID can't be parsed, the field is left out. This is meant for
+// debugging, not for being left on in production. The default is false.
+func SetRecordGoroutineID(record bool) {
+	recordGoroutineID = record
+}
+
+// versionKey and commitKey are the keys of the build info fields added when recordBuildInfo is set
+const (
+	versionKey = "version"
+	commitKey  = "commit"
+)
+
+// buildVersion and buildCommit are the build info set with SetBuildInfo
+var buildVersion, buildCommit string
+
+// recordBuildInfo controls whether constructors add the build info to errors
+var recordBuildInfo = false
+
+// SetBuildInfo sets the version and commit of the running program, e.g. ones set with -ldflags at
+// build time, for SetRecordBuildInfo to add to errors. Empty values aren't added.
+func SetBuildInfo(version, commit string) {
+	buildVersion, buildCommit = version, commit
+}
+
+// recordedBuildInfo marks the build info fields added by constructors, so that they can be told
+// apart from user fields with the same keys and values
+type recordedBuildInfo struct{}
+
+// buildInfoField returns a string field with the given key and value that's marked as recorded
+// build info. The marker is in the Interface of the field, which string fields don't otherwise use
+func buildInfoField(key, value string) zap.Field {
+	f := zap.String(key, value)
+	f.Interface = recordedBuildInfo{}
+	return f
+}
+
+// SetRecordBuildInfo controls whether New, Wrap and the other constructors that create an error with
+// its own message add the build info set with SetBuildInfo to it, for correlating errors with
+// deploys. The version is added under the "version" key and the commit under "commit":
+// 	{"msg":"connection error","version":"1.4.2","commit":"9f2c1e7"}
+// Like the "ts" field of SetRecordTime, the fields are added to every error created while the option
+// is on, including each error of a chain. The default is false.
+func SetRecordBuildInfo(record bool) {
+	recordBuildInfo = record
+}
+
+// logQueryArgs controls whether WithQuery stores the arguments of queries
+var logQueryArgs = false
+
+// SetLogQueryArgs controls whether WithQuery stores the arguments of the query as well, under the
+// "queryArgs" key. The arguments often contain personally identifiable information or secrets, so
+// this is meant for debugging; by default only the number of arguments is stored. The setting
+// affects errors created with WithQuery after it's changed, not existing ones.
+func SetLogQueryArgs(log bool) {
+	logQueryArgs = log
+}
+
+// fieldTransformer is applied to fields before they're serialized, if set
+var fieldTransformer func(zapcore.Field) zapcore.Field
+
+// SetFieldTransformer sets a function that's applied to every field of every error in the chain
+// when structured errors are serialized, i.e. by JSON(), MarshalLogObject and so on. The function
+// can return the field as-is, return a modified copy to e.g. rename its key or mask its value, or
+// return zap.Skip() to drop the field:
+// 	erreur.SetFieldTransformer(func(f zapcore.Field) zapcore.Field {
+// 		if f.Key == "password" {
+// 			return zap.Skip()
+// 		}
+// 		return f
+// 	})
+// It isn't called for skipped fields or for the fields erreur itself adds, like "cause" and
+// "stacktrace", and doesn't affect Error() or accessors like StringField. The transformation is done
+// before the other serialization options like SetTypedFields are applied. A nil function, the
+// default, disables transformation.
+func SetFieldTransformer(transform func(zapcore.Field) zapcore.Field) {
+	fieldTransformer = transform
+}
+
+// maxOutputBytes is the maximum size of JSON output, or 0 for no limit
+var maxOutputBytes = 0
+
+// SetMaxOutputBytes limits the size of the JSON produced by JSON(), JSONBuffer(), MarshalJSON()
+// and the other functions that serialize errors to JSON, so that e.g. a huge zap.Reflect field
+// doesn't produce megabytes of output. When the output would be larger than n bytes, the fields
+// that don't fit are dropped, starting from the first one that doesn't, and a "truncated":true
+// marker is added in their place, so the output is still valid JSON. A cause counts as a single
+// field, so it's kept entirely or dropped entirely. If even the message doesn't fit, it's shortened.
+// The output can't be smaller than an object with an empty message and the marker (28 bytes with
+// the trailing newline), so smaller limits produce that.
+//
+// The output is written through an encoder that stops at the limit, so encoding never uses more
+// memory than that, except for zap.Reflect values, which encoding/json marshals in full before
+// they're cut off. The limit doesn't apply when errors are logged as objects with Field, since the
+// log entry is encoded by the logger. n <= 0 means no limit, which is the default.
+func SetMaxOutputBytes(n int) {
+	maxOutputBytes = n
+}
+
+// wrapCountKey is the key of the field that counts the wraps collapsed because of maxWrapDepth
+const wrapCountKey = "wrapCount"
+
+// maxWrapDepth is the maximum number of errors in a cause chain Wrap creates, or 0 for no limit
+var maxWrapDepth = 0
+
+// SetMaxWrapDepth bounds the length of cause chains built with Wrap and Wrapef, so that e.g. a
+// retry loop that wraps the same error over and over doesn't grow it without bound. When the cause
+// chain already has n errors and the cause is structured, wrapping it doesn't add a new error:
+// the cause is returned with its "wrapCount" field incremented instead, and the message and fields
+// of the wrap are dropped:
+// 	{"msg":"retrying","wrapCount":7,"cause":{"msg":"connection refused"}}
+// Chains whose outermost error isn't structured are wrapped as usual. n <= 0 means no limit, which
+// is the default.
+func SetMaxWrapDepth(n int) {
+	maxWrapDepth = n
+}
+
+// collapseWrap returns cause with its wrap count incremented if wrapping it would make its chain
+// longer than maxWrapDepth
+func collapseWrap(cause error) (Structured, bool) {
+	if maxWrapDepth <= 0 {
+		return Structured{}, false
+	}
+	stre, ok := cause.(Structured)
+	if !ok {
+		return Structured{}, false
+	}
+	depth := 0
+	for err := cause; err != nil && depth < maxWrapDepth; depth++ {
+		w, ok := err.(wrapper)
+		if !ok {
+			err = nil
+		} else {
+			err = w.Unwrap()
+		}
+	}
+	if depth < maxWrapDepth {
+		return Structured{}, false
+	}
+	var count int64
+	if f, ok := stre.field(wrapCountKey); ok && f.Type == zapcore.Int64Type {
+		count = f.Integer
+	}
+	return stre.SetField(zap.Int64(wrapCountKey, count+1)), true
+}
@@ -0,0 +1,590 @@
+package erreur
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// logLine logs err with Field using a JSON encoder without timestamps, and returns the log line
+func logLine(err error) string {
+	return logFields(Field(err))
+}
+
+// logFields logs fields like logLine does
+func logFields(fields ...zapcore.Field) string {
+	buf := &bytes.Buffer{}
+	enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg", LineEnding: "\n"})
+	logger := zap.New(zapcore.NewCore(enc, zapcore.AddSync(buf), zapcore.DebugLevel))
+	logger.Error("failed to load data", fields...)
+	return buf.String()
+}
+
+func TestSetNestedMessageKey(t *testing.T) {
+	err := Wrap(errors.Join(String("disk full")), "writing failed", zap.String("file", "f"))
+	wrapped := Wrap(err, "flush failed")
+
+	const (
+		wantDefaultJSON = `{"msg":"flush failed","cause":{"msg":"writing failed","file":"f","cause":{"errors":[{"msg":"disk full"}]}}}` + "\n"
+		wantDefaultLog  = `{"msg":"failed to load data","error":{"msg":"flush failed","cause":{"msg":"writing failed","file":"f","cause":{"errors":[{"msg":"disk full"}]}}}}` + "\n"
+		wantRenamedJSON = `{"msg":"flush failed","cause":{"errorMsg":"writing failed","file":"f","cause":{"errors":[{"errorMsg":"disk full"}]}}}` + "\n"
+		wantRenamedLog  = `{"msg":"failed to load data","error":{"errorMsg":"flush failed","cause":{"errorMsg":"writing failed","file":"f","cause":{"errors":[{"errorMsg":"disk full"}]}}}}` + "\n"
+	)
+
+	if got := mustStructured(t, wrapped).JSON(); got != wantDefaultJSON {
+		t.Errorf("got default JSON\n%s\nwant\n%s", got, wantDefaultJSON)
+	}
+	if got := logLine(wrapped); got != wantDefaultLog {
+		t.Errorf("got default log line\n%s\nwant\n%s", got, wantDefaultLog)
+	}
+
+	SetNestedMessageKey("errorMsg")
+	defer SetNestedMessageKey("msg")
+
+	if got := mustStructured(t, wrapped).JSON(); got != wantRenamedJSON {
+		t.Errorf("got renamed JSON\n%s\nwant\n%s", got, wantRenamedJSON)
+	}
+	if got := logLine(wrapped); got != wantRenamedLog {
+		t.Errorf("got renamed log line\n%s\nwant\n%s", got, wantRenamedLog)
+	}
+}
+
+func TestSetHTMLEscape(t *testing.T) {
+	const input = "<script>alert('x')</script> &\u2028\u2029"
+	stre := mustStructured(t, New("bad input", zap.String("input", input)))
+
+	const (
+		wantDefault = `{"msg":"bad input","input":"` + input + `"}` + "\n"
+		wantEscaped = `{"msg":"bad input","input":"\u003cscript\u003ealert('x')\u003c/script\u003e \u0026\u2028\u2029"}` + "\n"
+	)
+
+	if got := stre.JSON(); got != wantDefault {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, wantDefault)
+	}
+
+	SetHTMLEscape(true)
+	defer SetHTMLEscape(false)
+
+	if got := stre.JSON(); got != wantEscaped {
+		t.Errorf("got escaped JSON\n%s\nwant\n%s", got, wantEscaped)
+	}
+
+	bs, err := json.Marshal(map[string]interface{}{"error": stre})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Error struct{ Input string }
+	}
+	if err := json.Unmarshal(bs, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Error.Input != input {
+		t.Errorf("escaped JSON decoded to %q, want %q", decoded.Error.Input, input)
+	}
+}
+
+func TestSetTypedFields(t *testing.T) {
+	err := Wrap(New("connection error", zap.Int("code", 1234), zap.String("addr", "example.com")),
+		"loading failed", zap.Bool("retried", true), zap.Skip())
+	stre := mustStructured(t, err)
+
+	const (
+		wantDefault = `{"msg":"loading failed","retried":true,"cause":{"msg":"connection error","code":1234,"addr":"example.com"}}` + "\n"
+		wantTyped   = `{"msg":"loading failed","retried":{"value":true,"type":"bool"},"cause":{"msg":"connection error",` +
+			`"code":{"value":1234,"type":"int64"},"addr":{"value":"example.com","type":"string"}}}` + "\n"
+	)
+
+	if got := stre.JSON(); got != wantDefault {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, wantDefault)
+	}
+
+	SetTypedFields(true)
+	defer SetTypedFields(false)
+
+	if got := stre.JSON(); got != wantTyped {
+		t.Errorf("got typed JSON\n%s\nwant\n%s", got, wantTyped)
+	}
+}
+
+func TestSetGroupByDotPrefix(t *testing.T) {
+	err := Wrap(New("dial failed", zap.String("net.addr", "10.0.0.1")),
+		"connecting to db",
+		zap.String("db.host", "db.local"),
+		zap.String("user", "bob"),
+		zap.Int("db.port", 5432),
+		zap.Duration("db.conn.timeout", 0),
+		zap.Bool("trailing.", true),
+		zap.Bool(".leading", true))
+	stre := mustStructured(t, err)
+
+	const (
+		wantFlat = `{"msg":"connecting to db","db.host":"db.local","user":"bob","db.port":5432,"db.conn.timeout":0,` +
+			`"trailing.":true,".leading":true,"cause":{"msg":"dial failed","net.addr":"10.0.0.1"}}` + "\n"
+		wantGrouped = `{"msg":"connecting to db","db":{"host":"db.local","port":5432,"conn":{"timeout":0}},"user":"bob",` +
+			`"trailing.":true,".leading":true,"cause":{"msg":"dial failed","net":{"addr":"10.0.0.1"}}}` + "\n"
+	)
+
+	if got := stre.JSON(); got != wantFlat {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, wantFlat)
+	}
+
+	SetGroupByDotPrefix(true)
+	defer SetGroupByDotPrefix(false)
+
+	if got := stre.JSON(); got != wantGrouped {
+		t.Errorf("got grouped JSON\n%s\nwant\n%s", got, wantGrouped)
+	}
+
+	t.Run("typed", func(t *testing.T) {
+		SetTypedFields(true)
+		defer SetTypedFields(false)
+
+		const want = `{"msg":"connection error","db":{"port":{"value":5432,"type":"int64"}}}` + "\n"
+		if got := mustStructured(t, New("connection error", zap.Int("db.port", 5432))).JSON(); got != want {
+			t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+		}
+	})
+}
+
+func TestSetEmptyMessagePlaceholder(t *testing.T) {
+	empty := errors.New("")
+	err := Wrap(Structure(empty, zap.String("user", "bob")), "login failed")
+	stre := mustStructured(t, err)
+
+	const (
+		wantDefault     = `{"msg":"login failed","cause":{"msg":"","user":"bob"}}` + "\n"
+		wantPlaceholder = `{"msg":"login failed","cause":{"msg":"<empty>","user":"bob"}}` + "\n"
+		wantTopLevel    = `{"msg":"<empty>","user":"bob"}` + "\n"
+		wantJoined      = `{"msg":"; disk full","errors":[{"msg":"<empty>"},{"msg":"disk full"}]}` + "\n"
+	)
+
+	if got := stre.JSON(); got != wantDefault {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, wantDefault)
+	}
+
+	SetEmptyMessagePlaceholder("<empty>")
+	defer SetEmptyMessagePlaceholder("")
+
+	if got := stre.JSON(); got != wantPlaceholder {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, wantPlaceholder)
+	}
+	if got := mustStructured(t, Structure(empty, zap.String("user", "bob"))).JSON(); got != wantTopLevel {
+		t.Errorf("got top-level JSON\n%s\nwant\n%s", got, wantTopLevel)
+	}
+	if got := mustStructured(t, Combine(empty, String("disk full"))).JSON(); got != wantJoined {
+		t.Errorf("got joined JSON\n%s\nwant\n%s", got, wantJoined)
+	}
+	if got := err.Error(); got != "login failed: " {
+		t.Errorf("Error() was affected, got %q", got)
+	}
+}
+
+func TestSetChainAsArray(t *testing.T) {
+	err := Wrap(Wrap(New("disk full", zap.String("device", "sda")), "write failed", zap.String("file", "f")),
+		"flush failed", zap.Int("attempt", 3))
+	stre := mustStructured(t, err)
+
+	const (
+		wantNested = `{"msg":"flush failed","attempt":3,"cause":{"msg":"write failed","file":"f","cause":{"msg":"disk full","device":"sda"}}}` + "\n"
+		wantArray  = `{"msg":"flush failed","attempt":3,"chain":[{"msg":"write failed","file":"f"},{"msg":"disk full","device":"sda"}]}` + "\n"
+		wantLog    = `{"msg":"failed to load data","error":{"msg":"flush failed","attempt":3,"chain":[{"msg":"write failed","file":"f"},{"msg":"disk full","device":"sda"}]}}` + "\n"
+		wantMulti  = `{"msg":"outer","chain":[{"msg":"inner","cause":{"errors":[{"msg":"a"},{"msg":"b"}]}}]}` + "\n"
+	)
+
+	if got := stre.JSON(); got != wantNested {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, wantNested)
+	}
+
+	SetChainAsArray(true)
+	defer SetChainAsArray(false)
+
+	if got := stre.JSON(); got != wantArray {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, wantArray)
+	}
+	if got := logLine(err); got != wantLog {
+		t.Errorf("got log line\n%s\nwant\n%s", got, wantLog)
+	}
+	multi := Wrap(Wrap(errors.Join(String("a"), String("b")), "inner"), "outer")
+	if got := mustStructured(t, multi).JSON(); got != wantMulti {
+		t.Errorf("got JSON with a multi-error\n%s\nwant\n%s", got, wantMulti)
+	}
+}
+
+func TestSetRecordTime(t *testing.T) {
+	created := time.Date(2019, 7, 1, 12, 30, 0, 500000000, time.UTC)
+	defer setNow(created)()
+
+	if _, ok := mustStructured(t, New("connection error")).Time(); ok {
+		t.Error("time was recorded with SetRecordTime off")
+	}
+
+	SetRecordTime(true)
+	defer SetRecordTime(false)
+
+	fields := make([]zap.Field, 1, 10)
+	fields[0] = zap.Int("code", 1234)
+	leaf := mustStructured(t, New("connection error", fields...))
+	if extra := fields[:cap(fields)][1]; extra.Key != "" {
+		t.Errorf("caller's fields were appended to: %v", extra)
+	}
+
+	const wantLeaf = `{"msg":"connection error","code":1234,"ts":1561984200.5}` + "\n"
+	if got := leaf.JSON(); got != wantLeaf {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, wantLeaf)
+	}
+	if got, ok := leaf.Time(); !ok || !got.Equal(created) {
+		t.Errorf("Time returned %v, %v, want %v", got, ok, created)
+	}
+
+	for name, err := range map[string]error{
+		"Wrap":          Wrap(leaf, "loading failed"),
+		"Wrapef":        Wrapef(leaf, nil, "loading %s failed", "user"),
+		"WrapWithStack": WrapWithStack(leaf, "loading failed"),
+		"FromCatalog":   FromCatalog("NO_SUCH_CODE"),
+	} {
+		if got, ok := mustStructured(t, err).Time(); !ok || !got.Equal(created) {
+			t.Errorf("%s: Time returned %v, %v, want %v", name, got, ok, created)
+		}
+	}
+	if _, ok := mustStructured(t, Structure(leaf, zap.String("user", "bob"))).Time(); ok {
+		t.Error("Structure recorded a time")
+	}
+}
+
+func TestSetFieldTransformer(t *testing.T) {
+	err := Wrap(New("login failed", zap.String("user", "bob"), zap.String("password", "hunter2")),
+		"request failed", zap.String("password", "hunter3"), zap.Int("status", 401), zap.Skip())
+	stre := mustStructured(t, err)
+
+	const wantDefault = `{"msg":"request failed","password":"hunter3","status":401,"cause":{"msg":"login failed","user":"bob","password":"hunter2"}}` + "\n"
+	if got := stre.JSON(); got != wantDefault {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, wantDefault)
+	}
+	defer SetFieldTransformer(nil)
+
+	t.Run("rename", func(t *testing.T) {
+		SetFieldTransformer(func(f zapcore.Field) zapcore.Field {
+			if f.Key == "user" {
+				f.Key = "userName"
+			}
+			return f
+		})
+		const want = `{"msg":"request failed","password":"hunter3","status":401,"cause":{"msg":"login failed","userName":"bob","password":"hunter2"}}` + "\n"
+		if got := stre.JSON(); got != want {
+			t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		SetFieldTransformer(func(f zapcore.Field) zapcore.Field {
+			if f.Key == "password" {
+				return zap.Skip()
+			}
+			return f
+		})
+		const (
+			want    = `{"msg":"request failed","status":401,"cause":{"msg":"login failed","user":"bob"}}` + "\n"
+			wantLog = `{"msg":"failed to load data","error":{"msg":"request failed","status":401,"cause":{"msg":"login failed","user":"bob"}}}` + "\n"
+		)
+		if got := stre.JSON(); got != want {
+			t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+		}
+		if got := logLine(err); got != wantLog {
+			t.Errorf("got log line\n%s\nwant\n%s", got, wantLog)
+		}
+		if v, _ := stre.StringField("password"); v != "hunter3" {
+			t.Errorf("the field itself was modified, got %q", v)
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		SetFieldTransformer(nil)
+		if got := stre.JSON(); got != wantDefault {
+			t.Errorf("got JSON\n%s\nwant\n%s", got, wantDefault)
+		}
+	})
+}
+
+func TestSetMaxOutputBytes(t *testing.T) {
+	huge := make([]int, 10000)
+	err := Wrap(New("inner"), "request failed",
+		zap.String("user", "bob"),
+		zap.Reflect("payload", huge),
+		zap.Int("status", 500))
+	stre := mustStructured(t, err)
+
+	full := stre.JSON()
+	if len(full) < 20000 {
+		t.Fatalf("test error is too small: %d bytes", len(full))
+	}
+
+	SetMaxOutputBytes(100)
+	defer SetMaxOutputBytes(0)
+
+	tests := []struct {
+		name string
+		s    Structured
+		want string
+	}{
+		{"field too large", stre, `{"msg":"request failed","user":"bob","truncated":true}`},
+		{"under the limit", mustStructured(t, New("small", zap.Int("n", 1))), `{"msg":"small","n":1}`},
+		{"message too large", mustStructured(t, New(strings.Repeat("é", 100))),
+			`{"msg":"` + strings.Repeat("é", 36) + `","truncated":true}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.s.JSON()
+			if got != tt.want+"\n" {
+				t.Errorf("got JSON\n%s\nwant\n%s", got, tt.want)
+			}
+			if len(got) > 100 {
+				t.Errorf("output is %d bytes, over the limit", len(got))
+			}
+			bs, _ := tt.s.MarshalJSON()
+			if !json.Valid(bs) {
+				t.Errorf("MarshalJSON output isn't valid JSON: %s", bs)
+			}
+		})
+	}
+
+	t.Run("limit at a field boundary", func(t *testing.T) {
+		want := `{"msg":"request failed","user":"bob","truncated":true}` + "\n"
+		SetMaxOutputBytes(len(want))
+		if got := stre.JSON(); got != want {
+			t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("memory bounded", func(t *testing.T) {
+		SetMaxOutputBytes(100)
+		large := mustStructured(t, New("request failed", zap.String("body", strings.Repeat("x", 10<<20))))
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		got := large.JSON()
+		runtime.ReadMemStats(&after)
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<10 {
+			t.Errorf("allocated %d bytes to produce %d bytes of output", allocated, len(got))
+		}
+	})
+
+	t.Run("html escaped", func(t *testing.T) {
+		SetHTMLEscape(true)
+		defer SetHTMLEscape(false)
+		SetMaxOutputBytes(60)
+
+		got := mustStructured(t, New(strings.Repeat("<", 50))).JSON()
+		if len(got) > 60 || !json.Valid([]byte(got)) || !strings.Contains(got, `"truncated":true`) {
+			t.Errorf("got %d bytes of output: %s", len(got), got)
+		}
+	})
+}
+
+func TestSetMaxOutputBytes_sameOutput(t *testing.T) {
+	err := Wrap(New("inner\x01 \xff", zap.Time("at", time.Unix(1561984200, 5e8)), zap.Duration("took", 1500*time.Millisecond)),
+		"request <failed> & \"quoted\"\n",
+		zap.String("user", "bob\t\u2028"),
+		zap.Int("n", -3), zap.Uint8("u", 7), zap.Bool("ok", false),
+		zap.Float64("nan", math.NaN()), zap.Float32("f", 1.5), zap.Complex128("c", complex(1, -2)),
+		zap.Binary("bin", []byte{0xff, 0}), zap.ByteString("bs", []byte("a\"b")),
+		zap.Reflect("payload", map[string][]int{"a": {1, 2}}),
+		zap.Strings("tags", []string{"x", "y"}), zap.Error(errors.New("EOF")),
+		zap.Namespace("ns"), zap.String("inside", "v"))
+	stre := mustStructured(t, err)
+
+	for _, escape := range []bool{false, true} {
+		SetHTMLEscape(escape)
+		want := stre.JSON()
+		SetMaxOutputBytes(len(want))
+		got := stre.JSON()
+		SetMaxOutputBytes(0)
+		SetHTMLEscape(false)
+		if got != want {
+			t.Errorf("got output\n%s\nwant the same as without a limit\n%s", got, want)
+		}
+	}
+}
+
+func TestSetRecordGoroutineID(t *testing.T) {
+	if _, ok := mustStructured(t, New("connection error")).field(goIDKey); ok {
+		t.Error("goroutine ID was recorded with SetRecordGoroutineID off")
+	}
+
+	SetRecordGoroutineID(true)
+	defer SetRecordGoroutineID(false)
+
+	ids := make(chan int64)
+	go func() {
+		var decoded struct{ GoID int64 }
+		if err := json.Unmarshal([]byte(New("connection error").(Structured).JSON()), &decoded); err != nil {
+			t.Error(err)
+		}
+		ids <- decoded.GoID
+	}()
+	other := <-ids
+
+	own, ok := mustStructured(t, Wrap(New("a"), "b")).field(goIDKey)
+	if !ok || own.Integer <= 0 {
+		t.Fatalf("got goID field %v, %v, want a positive number", own, ok)
+	}
+	if other <= 0 || other == own.Integer {
+		t.Errorf("got goID %d in another goroutine, want a positive number other than %d", other, own.Integer)
+	}
+}
+
+func TestSetRecordBuildInfo(t *testing.T) {
+	SetBuildInfo("1.4.2", "9f2c1e7")
+	defer SetBuildInfo("", "")
+
+	if _, ok := mustStructured(t, New("connection error")).field(versionKey); ok {
+		t.Error("build info was recorded with SetRecordBuildInfo off")
+	}
+
+	SetRecordBuildInfo(true)
+	defer SetRecordBuildInfo(false)
+
+	err := Wrap(New("connection error", zap.Int("code", 1234)), "loading failed")
+	const want = `{"msg":"loading failed","version":"1.4.2","commit":"9f2c1e7","cause":{"msg":"connection error","code":1234,"version":"1.4.2","commit":"9f2c1e7"}}` + "\n"
+	if got := err.(Structured).JSON(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if err := CheckReservedKeys(err); err != nil {
+		t.Errorf("the recorded build info was reported as reserved: %v", err)
+	}
+	if err := CheckReservedKeys(New("x", zap.String("version", "2"))); !errors.Is(err, ErrReservedKey) {
+		t.Errorf("got %v for a user field with a build info key", err)
+	}
+	if err := CheckReservedKeys(Wrap(err, "y", zap.String("commit", "9f2c1e7"))); !errors.Is(err, ErrReservedKey) {
+		t.Errorf("got %v for a user field with the same key and value as the recorded build info", err)
+	}
+
+	SetBuildInfo("1.4.2", "")
+	if got, want := New("no commit").(Structured).JSON(), `{"msg":"no commit","version":"1.4.2"}`+"\n"; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSetMaxWrapDepth(t *testing.T) {
+	SetMaxWrapDepth(3)
+	defer SetMaxWrapDepth(0)
+
+	err := New("connection refused")
+	for i := 0; i < 10; i++ {
+		err = Wrap(err, "retrying", zap.Int("attempt", i))
+	}
+
+	const want = `{"msg":"retrying","attempt":1,"wrapCount":8,"cause":{"msg":"retrying","attempt":0,"cause":{"msg":"connection refused"}}}` + "\n"
+	if got := mustStructured(t, err).JSON(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// chains under the limit are wrapped as usual
+	short := Wrapef(New("a"), nil, "b")
+	if got := mustStructured(t, short).JSON(); got != `{"msg":"b","cause":{"msg":"a"}}`+"\n" {
+		t.Errorf("got %s for a chain under the limit", got)
+	}
+
+	// a plain outermost error isn't collapsed
+	plain := fmt.Errorf("handler: %w", Wrap(Wrap(New("a"), "b"), "c"))
+	if got := mustStructured(t, Wrap(plain, "d")).Message(); got != "d" {
+		t.Errorf("got message %q after wrapping a plain error, want %q", got, "d")
+	}
+}
+
+func TestSetStripControlChars(t *testing.T) {
+	err := mustStructured(t, Wrap(errors.New("upstream said:\nbad\x00 gateway"), "request failed\r\n", zap.String("body", "a\nb")))
+
+	const wantRaw = "request failed\r\n: upstream said:\nbad\x00 gateway"
+	if got := err.Error(); got != wantRaw {
+		t.Errorf("got Error() %q with stripping off, want %q", got, wantRaw)
+	}
+
+	SetStripControlChars(true)
+	defer SetStripControlChars(false)
+
+	const wantErr = "request failed  : upstream said: bad gateway"
+	if got := err.Error(); got != wantErr {
+		t.Errorf("got Error() %q, want %q", got, wantErr)
+	}
+
+	leaf := mustStructured(t, New("tab\there\x7f", zap.String("body", "a\nb")))
+	const wantJSON = `{"msg":"failed to load data","error":{"msg":"wrapped  ","body":"a\nb","cause":{"msg":"tab here","body":"a\nb"}}}` + "\n"
+	if got := logLine(mustStructured(t, Wrap(leaf, "wrapped\r\n", zap.String("body", "a\nb")))); got != wantJSON {
+		t.Errorf("got log line\n%s\nwant\n%s", got, wantJSON)
+	}
+	if got := leaf.JSON(); got != `{"msg":"tab here","body":"a\nb"}`+"\n" {
+		t.Errorf("got JSON %s", got)
+	}
+}
+
+func TestSetPlainCauseAsObject(t *testing.T) {
+	err := mustStructured(t, Wrap(Wrap(String("EOF"), "reading header failed", zap.Int("offset", 42)), "loading failed"))
+	structure := mustStructured(t, Structure(String("EOF"), zap.Int("offset", 42)))
+
+	const wantDefault = `{"msg":"loading failed","cause":{"msg":"reading header failed","offset":42}}` + "\n"
+	if got := err.JSON(); got != wantDefault {
+		t.Errorf("got\n%s\nwant\n%s", got, wantDefault)
+	}
+
+	SetPlainCauseAsObject(true)
+	defer SetPlainCauseAsObject(false)
+
+	const want = `{"msg":"loading failed","cause":{"msg":"reading header failed","offset":42,"cause":{"msg":"EOF"}}}` + "\n"
+	if got := err.JSON(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := structure.JSON(); got != `{"msg":"EOF","offset":42}`+"\n" {
+		t.Errorf("got %s for an error created with Structure", got)
+	}
+	if got := fmt.Sprint(fieldKeys(mustStructured(t, Wrap(fmt.Errorf("db: %w", New("dial failed")), "query failed")).Fields())); got != "[cause]" {
+		t.Errorf("got fields %s for a wrapped structured cause", got)
+	}
+}
+
+func TestSetErrorSeparator(t *testing.T) {
+	chain := Wrap(Wrap(New("disk full", zap.String("device", "sda")), "write failed"), "flush failed")
+	structure := Structure(Wrap(String("EOF"), "reading header failed"), zap.Int("offset", 42))
+
+	SetErrorSeparator(" -> ")
+	defer SetErrorSeparator(": ")
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"chain", chain, "flush failed -> write failed -> disk full"},
+		{"plain root cause", Wrap(String("EOF"), "reading failed"), "reading failed -> EOF"},
+		{"Structure", structure, "reading header failed -> EOF"},
+		{"fmt wrapper", Wrap(fmt.Errorf("dialing: %w", String("refused")), "connect"), "connect -> dialing: refused"},
+		{"Errorf", Errorf("loading: %w", Wrap(New("a"), "b")), "loading: b -> a"},
+		{"Errorf without a suffix", Errorf("%w (loading)", Wrap(New("a"), "b")), "b -> a (loading)"},
+		{"wrapped Errorf", Wrap(Errorf("loading %d: %w", 1, String("EOF")), "sync"), "sync -> loading 1: EOF"},
+		{"leaf", New("disk full"), "disk full"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	IncludeFieldsInError = true
+	defer func() { IncludeFieldsInError = false }()
+	if got, want := chain.Error(), "flush failed -> write failed -> disk full {device=sda}"; got != want {
+		t.Errorf("got %q with fields, want %q", got, want)
+	}
+}
@@ -0,0 +1,233 @@
+package erreur
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ParseLogLine reconstructs a structured error from a JSON log line written by zap, for offline
+// tools that analyze logs. If the line has an "error" object, like the lines of errors logged with
+// Field, the error is parsed from that object and the rest of the line is ignored. Otherwise the
+// line itself is parsed as an error, so the output of JSON() can be parsed too.
+//
+// The message is read from the "msg" key of the line, or the key set with SetNestedMessageKey for
+// the "error" object and causes, and the other keys become fields in the order they appear in,
+// with "cause" objects and "errors" arrays turned back into causes. Since JSON has fewer types than
+// zap, the values come back as the JSON types they were serialized as: integers as zap.Int64,
+// other numbers as zap.Float64 (so e.g. durations and times are float seconds), strings as
+// zap.String and bools as zap.Bool. Objects, arrays and nulls are kept as raw JSON in zap.Reflect
+// fields, so they serialize the same as before. Multi-error causes come back as errors like the
+// ones Combine creates. Plain causes aren't serialized, so they can't be recovered
+func ParseLogLine(jsonLine []byte) (Structured, error) {
+	line, err := decodeTopLevel(jsonLine)
+	if err != nil {
+		return Structured{}, fmt.Errorf("erreur: parsing log line: %w", err)
+	}
+
+	for _, m := range line {
+		if m.key == "error" && isObject(m.value) {
+			nested, err := decodeRawObject(m.value)
+			if err != nil {
+				return Structured{}, fmt.Errorf("erreur: parsing log line: %w", err)
+			}
+			return errorFromMembers(nested, nestedMessageKey, 0)
+		}
+	}
+	return errorFromMembers(line, "msg", 0)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It parses the JSON serialization of a structured
+// error, as produced by MarshalJSON, the same way ParseLogLine parses log lines without an "error"
+// object, so the parsed error serializes to the same JSON, but its fields have the JSON types of
+// their values (see ParseLogLine for details).
+//
+// Malformed input makes it return an error, never panic. Errors nested more than 100 causes deep
+// are rejected, and invalid UTF-8 in strings is replaced with U+FFFD like encoding/json does
+func (s *Structured) UnmarshalJSON(data []byte) error {
+	members, err := decodeTopLevel(data)
+	if err != nil {
+		return fmt.Errorf("erreur: parsing error: %w", err)
+	}
+	parsed, err := errorFromMembers(members, "msg", 0)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// maxParseDepth is the deepest nesting of causes ParseLogLine and UnmarshalJSON accept
+const maxParseDepth = 100
+
+// decodeTopLevel decodes data, which must be a single JSON object, into its members
+func decodeTopLevel(data []byte) ([]jsonMember, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	members, err := decodeObject(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, errors.New("data after the JSON object")
+	}
+	return members, nil
+}
+
+// jsonMember is a key of a JSON object and its raw value
+type jsonMember struct {
+	key   string
+	value json.RawMessage
+}
+
+// decodeObject decodes the next JSON object from dec into its members, keeping their order
+func decodeObject(dec *json.Decoder) ([]jsonMember, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object, got %v", tok)
+	}
+
+	var members []jsonMember
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected an object key, got %v", tok)
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		members = append(members, jsonMember{key: key, value: value})
+	}
+	if _, err := dec.Token(); err != nil { // the closing brace
+		return nil, err
+	}
+	return members, nil
+}
+
+func decodeRawObject(raw json.RawMessage) ([]jsonMember, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return decodeObject(dec)
+}
+
+func isObject(raw json.RawMessage) bool {
+	return len(raw) > 0 && raw[0] == '{'
+}
+
+// errorFromMembers builds a structured error from the members of a serialized error object whose
+// message is under msgKey. depth is the number of causes the object is nested in
+func errorFromMembers(members []jsonMember, msgKey string, depth int) (Structured, error) {
+	if depth > maxParseDepth {
+		return Structured{}, fmt.Errorf("erreur: causes nested more than %d deep", maxParseDepth)
+	}
+	var s Structured
+	for _, m := range members {
+		switch {
+		case m.key == msgKey && s.err == nil:
+			var msg string
+			if err := json.Unmarshal(m.value, &msg); err != nil {
+				return Structured{}, fmt.Errorf("erreur: parsing message: %w", err)
+			}
+			s.err = String(msg)
+		case m.key == "cause" && isObject(m.value) && s.causer == nil:
+			cause, err := causeFromJSON(m.value, depth+1)
+			if err != nil {
+				return Structured{}, err
+			}
+			s.causer = cause
+		case m.key == "errors" && len(m.value) > 0 && m.value[0] == '[' && s.causer == nil:
+			errs, err := errorsFromJSON(m.value, depth+1)
+			if err != nil {
+				return Structured{}, err
+			}
+			// a top-level "errors" array means s has no message of its own (see Fields), the one
+			// under msgKey is the joined message of the errors
+			s.causer, s.err = errs, nil
+			msgKey = ""
+		default:
+			f, err := fieldFromJSON(m.key, m.value)
+			if err != nil {
+				return Structured{}, err
+			}
+			s.fields = append(s.fields, f)
+		}
+	}
+
+	// an object without a message still has one, since serializing an error always writes one
+	if s.err == nil && msgKey != "" {
+		s.err = String("")
+	}
+	return s, nil
+}
+
+// causeFromJSON parses a "cause" object, which is either a structured error or the "errors" array
+// of a multi-error
+func causeFromJSON(raw json.RawMessage, depth int) (error, error) {
+	members, err := decodeRawObject(raw)
+	if err != nil {
+		return nil, fmt.Errorf("erreur: parsing cause: %w", err)
+	}
+	if len(members) == 1 && members[0].key == "errors" {
+		return errorsFromJSON(members[0].value, depth)
+	}
+	return errorFromMembers(members, nestedMessageKey, depth)
+}
+
+func errorsFromJSON(raw json.RawMessage, depth int) (combined, error) {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(raw, &elems); err != nil {
+		return nil, fmt.Errorf("erreur: parsing errors: %w", err)
+	}
+	errs := make(combined, 0, len(elems))
+	for _, elem := range elems {
+		members, err := decodeRawObject(elem)
+		if err != nil {
+			return nil, fmt.Errorf("erreur: parsing errors: %w", err)
+		}
+		e, err := errorFromMembers(members, nestedMessageKey, depth)
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, e)
+	}
+	return errs, nil
+}
+
+// fieldFromJSON returns a field with the given key for a raw JSON value
+func fieldFromJSON(key string, raw json.RawMessage) (zapcore.Field, error) {
+	switch raw[0] {
+	case '"':
+		var str string
+		if err := json.Unmarshal(raw, &str); err != nil {
+			return zapcore.Field{}, fmt.Errorf("erreur: parsing field %q: %w", key, err)
+		}
+		return zap.String(key, str), nil
+	case 't', 'f':
+		return zap.Bool(key, raw[0] == 't'), nil
+	case '{', '[', 'n':
+		return zap.Reflect(key, raw), nil
+	}
+
+	if i, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+		return zap.Int64(key, i), nil
+	}
+	fl, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil {
+		return zapcore.Field{}, fmt.Errorf("erreur: parsing field %q: %w", key, err)
+	}
+	return zap.Float64(key, fl), nil
+}
@@ -0,0 +1,156 @@
+package erreur
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseLogLine(t *testing.T) {
+	orig := mustStructured(t, Wrap(
+		Wrap(errors.Join(New("disk full", zap.String("device", "sda")), String("quota exceeded")), "write failed", zap.String("file", "f")),
+		"flush failed",
+		zap.Int("attempt", 3),
+		zap.Float64("load", 0.5),
+		zap.Bool("retried", true),
+		zap.Duration("took", 1500*time.Millisecond),
+		zap.Strings("hosts", []string{"a", "b"}),
+		zap.Object("db", fieldObject{zap.String("host", "db.local"), zap.Int("port", 5432)}),
+		zap.Reflect("nothing", nil),
+	))
+
+	// a full log line, with a level, a timestamp and fields of its own
+	buf := &bytes.Buffer{}
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	logger := zap.New(zapcore.NewCore(enc, zapcore.AddSync(buf), zapcore.DebugLevel))
+	logger.Error("failed to save", zap.String("requestID", "abc"), Field(orig))
+
+	parsed, err := ParseLogLine(buf.Bytes())
+	if err != nil {
+		t.Fatalf("got error %v for log line %s", err, buf.Bytes())
+	}
+
+	const want = `{"msg":"flush failed","attempt":3,"load":0.5,"retried":true,"took":1.5,"hosts":["a","b"],` +
+		`"db":{"host":"db.local","port":5432},"nothing":null,` +
+		`"cause":{"msg":"write failed","file":"f","cause":{"errors":[{"msg":"disk full","device":"sda"},{"msg":"quota exceeded"}]}}}` + "\n"
+	if got := parsed.JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+	// the errors of multi-errors come back joined like with Combine, not errors.Join
+	if got, want := parsed.Error(), "flush failed: write failed: disk full; quota exceeded"; got != want {
+		t.Errorf("got Error() %q, want %q", got, want)
+	}
+	if v, ok := parsed.IntField("attempt"); !ok || v != 3 {
+		t.Errorf("IntField returned %v, %v", v, ok)
+	}
+}
+
+func TestParseLogLine_json(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"leaf", New("connection error", zap.Int("code", 1234), zap.String("addr", "example.com"))},
+		{"wrapped", Wrap(New("inner", zap.Int("n", 1)), "outer", zap.Bool("b", true))},
+		{"combined", Combine(New("a", zap.Int("n", 1)), New("b"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := mustStructured(t, tt.err)
+			parsed, err := ParseLogLine([]byte(orig.JSON()))
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if got, want := parsed.JSON(), orig.JSON(); got != want {
+				t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+			}
+			if got, want := parsed.Error(), orig.Error(); got != want {
+				t.Errorf("got Error() %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestParseLogLine_invalid(t *testing.T) {
+	for _, line := range []string{``, `[]`, `{"msg":`, `{"msg":1}`, `{"msg":"a"} {}`, `"msg"`} {
+		if _, err := ParseLogLine([]byte(line)); err == nil {
+			t.Errorf("got no error for %q", line)
+		}
+	}
+}
+
+func TestStructured_UnmarshalJSON(t *testing.T) {
+	orig := mustStructured(t, Wrap(
+		Combine(New("disk full", zap.String("device", "sda")), String("quota exceeded")),
+		"flush failed", zap.Int("attempt", 3), zap.Reflect("opts", map[string]int{"n": 1})))
+
+	var parsed Structured
+	if err := json.Unmarshal([]byte(orig.JSON()), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := parsed.JSON(), orig.JSON(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// a log line is parsed as an error, not as the container of one
+	if err := json.Unmarshal([]byte(logLine(orig)), &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Message() != "failed to load data" {
+		t.Errorf("got message %q for a log line", parsed.Message())
+	}
+}
+
+func TestStructured_UnmarshalJSON_depth(t *testing.T) {
+	nested := func(depth int) []byte {
+		return []byte(strings.Repeat(`{"msg":"a","cause":`, depth) + `{"msg":"root"}` + strings.Repeat("}", depth))
+	}
+
+	var s Structured
+	if err := s.UnmarshalJSON(nested(maxParseDepth)); err != nil {
+		t.Errorf("got error %v for causes %d deep", err, maxParseDepth)
+	}
+	if err := s.UnmarshalJSON(nested(maxParseDepth + 1)); err == nil {
+		t.Errorf("got no error for causes %d deep", maxParseDepth+1)
+	}
+	if err := s.UnmarshalJSON([]byte("{\"msg\":\"bad \xff utf-8\"}")); err != nil || s.Message() != "bad � utf-8" {
+		t.Errorf("got %q, %v for invalid UTF-8", s.Message(), err)
+	}
+}
+
+func FuzzUnmarshalJSON(f *testing.F) {
+	for _, err := range []error{
+		New("connection error", zap.Int("code", 1234), zap.String("addr", "example.com")),
+		Wrap(Wrap(String("EOF"), "reading header failed", zap.Int("offset", 42)), "loading failed", zap.Duration("took", time.Second)),
+		Wrap(errors.Join(New("a", zap.Bool("ok", false)), String("b")), "both failed"),
+		Combine(New("first"), New("second", zap.Float64("load", 0.5))),
+		New("nested", zap.Namespace("db"), zap.String("host", "h"), zap.Reflect("opts", []int{1, 2})),
+	} {
+		f.Add([]byte(err.(Structured).JSON()))
+	}
+	f.Add([]byte(`{"msg":"dup","msg":"again","cause":"not an object","errors":[]}`))
+	f.Add([]byte(`{"msg":"a","cause":{"errors":[{"msg":"b","cause":{"msg":"c"}}]}}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var s Structured
+		if err := s.UnmarshalJSON(data); err != nil {
+			return
+		}
+		// whatever was parsed has to serialize to JSON that parses back to the same error
+		first := s.JSON()
+		var again Structured
+		if err := again.UnmarshalJSON([]byte(first)); err != nil {
+			t.Fatalf("parsing the serialization %s of %q failed: %v", first, data, err)
+		}
+		if second := again.JSON(); second != first {
+			t.Fatalf("serialization changed after a round trip:\n%s\n%s", first, second)
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package erreur
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/ORBAT/erreur/erreurpb"
+)
+
+// ToProto returns the protobuf representation of s, including its cause chain. Strings, integers,
+// floats, bools, binary, times and durations are stored as their protobuf equivalents. Values that
+// have none (objects, arrays, reflected values and so on) are stored as JSON, so serializing the
+// result of FromProto produces the same JSON as serializing s. Times lose their location, and
+// skipped fields are dropped.
+//
+// A cause that isn't structured and has no structured error in its chain is stored as a plain error
+// with only a message, and comes back from FromProto as a String. Structured errors wrapped in
+// non-structured ones, like in fmt.Errorf("context: %w", stre), are stored the way they're
+// serialized, with the wrapper's message ("context") as the message of a structured error
+func (s Structured) ToProto() *erreurpb.Error {
+	pe := &erreurpb.Error{Fields: make([]*erreurpb.Field, 0, len(s.fields))}
+	if s.err != nil {
+		msg := s.err.Error()
+		pe.Message = &msg
+	}
+
+	for _, f := range s.fields {
+		if pf, ok := fieldToProto(f); ok {
+			pe.Fields = append(pe.Fields, pf)
+		}
+	}
+
+	switch cause := s.causer.(type) {
+	case nil:
+	case multiWrapper:
+		for _, e := range cause.Unwrap() {
+			if e != nil {
+				pe.Joined = append(pe.Joined, errorToProto(e))
+			}
+		}
+	default:
+		pe.Cause = errorToProto(cause)
+	}
+
+	return pe
+}
+
+func errorToProto(e error) *erreurpb.Error {
+	// like in serialization, structured errors inside non-structured wrappers aren't lost
+	if stre, ok := liftStructured(e); ok {
+		return stre.ToProto()
+	}
+	msg := e.Error()
+	return &erreurpb.Error{Message: &msg, Plain: true}
+}
+
+// FromProto returns the structured error represented by pe, or nil if pe is nil. See ToProto for
+// what survives the round trip
+func FromProto(pe *erreurpb.Error) error {
+	if pe == nil {
+		return nil
+	}
+	if pe.Plain {
+		return String(pe.GetMessage())
+	}
+
+	fields := make([]zap.Field, 0, len(pe.Fields))
+	for _, pf := range pe.Fields {
+		fields = append(fields, fieldFromProto(pf))
+	}
+
+	// the error is built directly instead of with New and Wrap, so that the options that affect
+	// creating errors (like SetMaxWrapDepth or SetRecordTime) don't change the decoded chain
+	s := Structured{fields: fields}
+	if len(pe.Joined) > 0 {
+		errs := make([]error, len(pe.Joined))
+		for i, je := range pe.Joined {
+			errs[i] = FromProto(je)
+		}
+		s.causer = errors.Join(errs...)
+	} else if pe.Cause != nil {
+		s.causer = FromProto(pe.Cause)
+	}
+	if pe.Message != nil || s.causer == nil {
+		s.err = String(pe.GetMessage())
+	}
+	return s
+}
+
+func fieldToProto(f zapcore.Field) (*erreurpb.Field, bool) {
+	pf := &erreurpb.Field{Key: f.Key}
+
+	switch f.Type {
+	case zapcore.SkipType:
+		return nil, false
+	case zapcore.NamespaceType:
+		return pf, true
+	case zapcore.StringType:
+		pf.Value = &erreurpb.Field_StringValue{StringValue: f.String}
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		pf.Value = &erreurpb.Field_IntValue{IntValue: f.Integer}
+	case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type, zapcore.UintptrType:
+		pf.Value = &erreurpb.Field_UintValue{UintValue: uint64(f.Integer)}
+	case zapcore.Float64Type:
+		pf.Value = &erreurpb.Field_FloatValue{FloatValue: math.Float64frombits(uint64(f.Integer))}
+	case zapcore.Float32Type:
+		pf.Value = &erreurpb.Field_FloatValue{FloatValue: float64(math.Float32frombits(uint32(f.Integer)))}
+	case zapcore.BoolType:
+		pf.Value = &erreurpb.Field_BoolValue{BoolValue: f.Integer == 1}
+	case zapcore.BinaryType:
+		pf.Value = &erreurpb.Field_BytesValue{BytesValue: f.Interface.([]byte)}
+	case zapcore.ByteStringType:
+		pf.Value = &erreurpb.Field_StringValue{StringValue: string(f.Interface.([]byte))}
+	case zapcore.TimeType:
+		pf.Value = &erreurpb.Field_TimeValue{TimeValue: timestamppb.New(time.Unix(0, f.Integer))}
+	case zapcore.DurationType:
+		pf.Value = &erreurpb.Field_DurationValue{DurationValue: durationpb.New(time.Duration(f.Integer))}
+	default:
+		v, ok := fieldValue(f)
+		if !ok {
+			return nil, false
+		}
+		if str, ok := v.(string); ok { // stringers and errors
+			pf.Value = &erreurpb.Field_StringValue{StringValue: str}
+			break
+		}
+		bs, err := json.Marshal(v)
+		if err != nil {
+			pf.Value = &erreurpb.Field_StringValue{StringValue: err.Error()}
+			pf.Key = f.Key + "Error"
+			break
+		}
+		pf.Value = &erreurpb.Field_JsonValue{JsonValue: string(bs)}
+	}
+
+	return pf, true
+}
+
+func fieldFromProto(pf *erreurpb.Field) zap.Field {
+	switch v := pf.Value.(type) {
+	case *erreurpb.Field_StringValue:
+		return zap.String(pf.Key, v.StringValue)
+	case *erreurpb.Field_IntValue:
+		return zap.Int64(pf.Key, v.IntValue)
+	case *erreurpb.Field_UintValue:
+		return zap.Uint64(pf.Key, v.UintValue)
+	case *erreurpb.Field_FloatValue:
+		return zap.Float64(pf.Key, v.FloatValue)
+	case *erreurpb.Field_BoolValue:
+		return zap.Bool(pf.Key, v.BoolValue)
+	case *erreurpb.Field_BytesValue:
+		return zap.Binary(pf.Key, v.BytesValue)
+	case *erreurpb.Field_TimeValue:
+		return zap.Time(pf.Key, v.TimeValue.AsTime())
+	case *erreurpb.Field_DurationValue:
+		return zap.Duration(pf.Key, v.DurationValue.AsDuration())
+	case *erreurpb.Field_JsonValue:
+		return zap.Reflect(pf.Key, json.RawMessage(v.JsonValue))
+	default:
+		return zap.Namespace(pf.Key)
+	}
+}
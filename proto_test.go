@@ -0,0 +1,97 @@
+package erreur
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ORBAT/erreur/erreurpb"
+)
+
+func TestStructured_ToProto_roundTrip(t *testing.T) {
+	when := time.Date(2019, 7, 1, 12, 30, 0, 0, time.UTC)
+	root := Wrap(String("insufficient permissions"), "writing to file failed",
+		zap.String("fileName", "someFile"),
+		zap.Int("mode", 0600),
+		zap.Uint8("flags", 3),
+		zap.Float64("ratio", 0.25),
+		zap.Bool("retried", true),
+		zap.Binary("header", []byte{0xde, 0xad}),
+		zap.ByteString("raw", []byte("raw")),
+		zap.Time("when", when),
+		zap.Duration("took", 1500*time.Millisecond),
+		zap.Strings("tags", []string{"a", "b"}),
+		zap.Namespace("ns"),
+		zap.Int("inNs", 1),
+	)
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"leaf", New("connection error", zap.Int("code", 1234))},
+		{"chain", Wrap(root, "failed to flush db", zap.Skip(), zap.String("db", "users"))},
+		{"structure", Structure(Wrap(String("plain"), "structured"), zap.Int("n", 1))},
+		{"joined", Wrap(errors.Join(New("a", zap.Int("n", 1)), String("b")), "both failed")},
+		{"fmt wrapped", Wrap(fmt.Errorf("db: %w", New("dial", zap.Int("port", 1))), "q")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := mustStructured(t, tt.err)
+
+			bs, err := proto.Marshal(orig.ToProto())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var pe erreurpb.Error
+			if err := proto.Unmarshal(bs, &pe); err != nil {
+				t.Fatal(err)
+			}
+
+			got := mustStructured(t, FromProto(&pe))
+			if got.JSON() != orig.JSON() {
+				t.Errorf("got JSON\n%s\nwant\n%s", got.JSON(), orig.JSON())
+			}
+			if got.Error() != orig.Error() {
+				t.Errorf("got Error() %q, want %q", got.Error(), orig.Error())
+			}
+		})
+	}
+}
+
+func TestFromProto_creationOptions(t *testing.T) {
+	orig := mustStructured(t, Wrap(Wrap(Wrap(New("root", zap.Int("a", 1)), "l2"), "l3"), "l4"))
+	want := orig.JSON()
+
+	SetMaxWrapDepth(2)
+	defer SetMaxWrapDepth(0)
+	SetBuildInfo("1.4.2", "9f2c1e7")
+	defer SetBuildInfo("", "")
+	SetRecordBuildInfo(true)
+	defer SetRecordBuildInfo(false)
+	SetRecordTime(true)
+	defer SetRecordTime(false)
+	SetRecordGoroutineID(true)
+	defer SetRecordGoroutineID(false)
+	defer func(orig bool) { captureStacks = orig }(captureStacks)
+	captureStacks = true
+
+	got := mustStructured(t, FromProto(orig.ToProto()))
+	if got.JSON() != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got.JSON(), want)
+	}
+	if got.stack != nil {
+		t.Error("a stack was captured for the decoded error")
+	}
+}
+
+func TestFromProto_nil(t *testing.T) {
+	if err := FromProto(nil); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+}
@@ -0,0 +1,491 @@
+package erreur
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// keys of the fields used by the convenience methods in this file
+const (
+	codeKey       = "code"
+	operationKey  = "operation"
+	exitCodeKey   = "exitCode"
+	tagsKey       = "tags"
+	sourceKey     = "source"
+	levelKey      = "level"
+	httpStatusKey = "httpStatus"
+	userIDKey     = "userID"
+	queryKey      = "query"
+	queryArgsKey  = "queryArgs"
+	argCountKey   = "queryArgCount"
+	categoryKey   = "category"
+	attemptKey    = "attempt"
+	latencyKey    = "latency"
+)
+
+// ReservedKeys returns the keys erreur uses in the serialized form of structured errors, in sorted
+// order: "msg", which JSON() writes the message under, the message key of nested errors if it's
+// been changed with SetNestedMessageKey, "cause", "errors" and "chain" for causes, "stacktrace",
+// the "..." marker of causes left out, "truncated" if SetMaxOutputBytes is on, "ts" and "goID" if
+// SetRecordTime or SetRecordGoroutineID is on, and "version" and "commit" if SetRecordBuildInfo
+// is. A field of an error with one of these keys ends up next to erreur's own key in the output,
+// which makes it ambiguous; NewStrict rejects such fields, and CheckReservedKeys finds them in
+// existing errors.
+//
+// The keys of the convenience methods like WithCode aren't reserved, since fields with those keys,
+// e.g. zap.String("code", "NOT_FOUND"), are how e.g. CodeOf is meant to find them
+func ReservedKeys() []string {
+	keys := []string{jsonEncConf.MessageKey, "cause", "errors", "chain", "stacktrace", moreCausesKey}
+	if nestedMessageKey != jsonEncConf.MessageKey {
+		keys = append(keys, nestedMessageKey)
+	}
+	if maxOutputBytes > 0 {
+		keys = append(keys, truncatedKey)
+	}
+	if recordTime {
+		keys = append(keys, timeKey)
+	}
+	if recordGoroutineID {
+		keys = append(keys, goIDKey)
+	}
+	if recordBuildInfo {
+		keys = append(keys, versionKey, commitKey)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isReserved returns true if key is one of ReservedKeys
+func isReserved(key string) bool {
+	if key == jsonEncConf.MessageKey || key == nestedMessageKey {
+		return true
+	}
+	switch key {
+	case "cause", "errors", "chain", "stacktrace", moreCausesKey:
+		return true
+	case truncatedKey:
+		return maxOutputBytes > 0
+	case timeKey:
+		return recordTime
+	case goIDKey:
+		return recordGoroutineID
+	case versionKey, commitKey:
+		return recordBuildInfo
+	}
+	return false
+}
+
+// ErrReservedKey is returned by NewStrict and CheckReservedKeys for fields that have a reserved key
+// (see ReservedKeys)
+const ErrReservedKey = String("field has a reserved key")
+
+// CheckReservedKeys returns an error matching ErrReservedKey with errors.Is if a structured error in
+// err's cause chain has a field with a reserved key, e.g. a user field named "cause", and nil if
+// none does. The error has the offending key in a "key" field. This is meant for tests and debug
+// builds, to catch fields that would make the output confusing:
+// 	if err := erreur.CheckReservedKeys(err); err != nil {
+// 		t.Error(err)
+// 	}
+// The "ts", "goID", "version" and "commit" fields that erreur itself records aren't reported.
+// Fields inside a namespace can't collide with erreur's keys, so they aren't checked
+func CheckReservedKeys(err error) error {
+	for err != nil {
+		if stre, ok := err.(Structured); ok {
+			if key, ok := stre.reservedKey(); ok {
+				return Structured{causer: ErrReservedKey, fields: []zap.Field{zap.String("key", key)}}
+			}
+		}
+		cause, ok := err.(wrapper)
+		if !ok {
+			break
+		}
+		err = cause.Unwrap()
+	}
+	return nil
+}
+
+// reservedKey returns the first reserved key among the fields of s that isn't a field erreur
+// records itself
+func (s Structured) reservedKey() (string, bool) {
+	for _, f := range s.fields {
+		switch {
+		case f.Type == zapcore.NamespaceType:
+			return "", false
+		case f.Type == zapcore.SkipType:
+		case f.Key == timeKey && f.Type == zapcore.TimeType, f.Key == goIDKey && f.Type == zapcore.Uint64Type:
+		case f.Interface == recordedBuildInfo{}:
+		case isReserved(f.Key):
+			return f.Key, true
+		}
+	}
+	return "", false
+}
+
+// chainField returns the first field with the given key in the structured errors of err's cause
+// chain, starting from err itself
+func chainField(err error, key string) (zapcore.Field, bool) {
+	for err != nil {
+		if stre, ok := err.(Structured); ok {
+			if f, ok := stre.field(key); ok {
+				return f, true
+			}
+		}
+		cause, ok := err.(wrapper)
+		if !ok {
+			break
+		}
+		err = cause.Unwrap()
+	}
+	return zapcore.Field{}, false
+}
+
+// rootChainField is like chainField, but returns the innermost matching field, i.e. the one
+// closest to the root cause
+func rootChainField(err error, key string) (f zapcore.Field, found bool) {
+	for err != nil {
+		if stre, ok := err.(Structured); ok {
+			if sf, ok := stre.field(key); ok {
+				f, found = sf, true
+			}
+		}
+		cause, ok := err.(wrapper)
+		if !ok {
+			break
+		}
+		err = cause.Unwrap()
+	}
+	return f, found
+}
+
+// WithCode returns a copy of s with code, which identifies the kind of error (e.g.
+// "USER_NOT_FOUND"), stored under the "code" key. Any previous code of s is replaced
+func (s Structured) WithCode(code string) Structured {
+	return s.SetField(zap.String(codeKey, code))
+}
+
+// CodeOf returns the code set with WithCode on err or an error in its cause chain. If several errors
+// in the chain have a code, the outermost one wins. Integer "code" fields like
+// 	erreur.New("connection error", zap.Int("code", 1234))
+// are returned in decimal form
+func CodeOf(err error) (code string, ok bool) {
+	f, ok := chainField(err, codeKey)
+	if !ok {
+		return "", false
+	}
+	return codeString(f)
+}
+
+// codeString returns the value of a "code" field as a string
+func codeString(f zapcore.Field) (string, bool) {
+	switch f.Type {
+	case zapcore.StringType:
+		return f.String, true
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		return strconv.FormatInt(f.Integer, 10), true
+	}
+	return "", false
+}
+
+// Codes returns the codes of s and the errors in its cause chain, from s to the root cause, with
+// duplicates removed. Like with CodeOf, integer codes are returned in decimal form. This is handy
+// for tagging errors that cross several layers that each have their own codes. Returns nil if there
+// are no codes
+func (s Structured) Codes() []string {
+	var codes tags
+	anyCode(s, func(code string) bool {
+		if !codes.has(code) {
+			codes = append(codes, code)
+		}
+		return false
+	})
+	return []string(codes)
+}
+
+// anyCode calls match with the codes of err and the errors in its cause chain, from err to the
+// root cause, until match returns true. Returns true if it did
+func anyCode(err error, match func(code string) bool) bool {
+	for err != nil {
+		if stre, ok := err.(Structured); ok {
+			if f, ok := stre.field(codeKey); ok {
+				if code, ok := codeString(f); ok && match(code) {
+					return true
+				}
+			}
+		}
+		cause, ok := err.(wrapper)
+		if !ok {
+			break
+		}
+		err = cause.Unwrap()
+	}
+	return false
+}
+
+// HasCode returns true if err or any error in its cause chain has the given code. Unlike comparing
+// the result of CodeOf, this also finds codes that an outer error's code shadows
+func HasCode(err error, code string) bool {
+	return anyCode(err, func(c string) bool { return c == code })
+}
+
+// ErrorSet is a set of codes for classifying errors by their code, e.g. to tell user errors from
+// internal ones:
+// 	userErrors := erreur.NewErrorSet("USER_NOT_FOUND", "INVALID_INPUT", "FORBIDDEN")
+// 	if userErrors.Contains(err) {
+// Checking an error against an ErrorSet costs the same no matter how many codes it has. An
+// ErrorSet must not be modified while it's used concurrently
+type ErrorSet map[string]struct{}
+
+// NewErrorSet returns an ErrorSet with the given codes
+func NewErrorSet(codes ...string) ErrorSet {
+	set := make(ErrorSet, len(codes))
+	for _, c := range codes {
+		set[c] = struct{}{}
+	}
+	return set
+}
+
+// Contains returns true if err or any error in its cause chain has a code in set, i.e. if HasCode
+// would return true for any of the codes
+func (set ErrorSet) Contains(err error) bool {
+	return anyCode(err, func(code string) bool {
+		_, ok := set[code]
+		return ok
+	})
+}
+
+// WithOperation returns a copy of s with op, which names the operation (e.g. an RPC method) that
+// failed, stored under the "operation" key. Any previous operation of s is replaced
+func (s Structured) WithOperation(op string) Structured {
+	return s.SetField(zap.String(operationKey, op))
+}
+
+// OperationOf returns the operation set with WithOperation on err or an error in its cause chain.
+// If several errors in the chain have an operation, the outermost one wins
+func OperationOf(err error) (op string, ok bool) {
+	f, ok := chainField(err, operationKey)
+	if !ok || f.Type != zapcore.StringType {
+		return "", false
+	}
+	return f.String, true
+}
+
+// WithExitCode returns a copy of s with the exit code a CLI should exit with because of s stored
+// under the "exitCode" key. Any previous exit code of s is replaced
+func (s Structured) WithExitCode(code int) Structured {
+	return s.SetField(zap.Int(exitCodeKey, code))
+}
+
+// ExitCodeOf returns the exit code set with WithExitCode on err or an error in its cause chain, so
+// main can do
+// 	os.Exit(erreur.ExitCodeOf(err))
+// If several errors in the chain have an exit code, the outermost one wins. Returns 1 for errors
+// with no exit code, and 0 if err is nil
+func ExitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	f, ok := chainField(err, exitCodeKey)
+	if !ok || f.Type != zapcore.Int64Type {
+		return 1
+	}
+	return int(f.Integer)
+}
+
+// WithLevel returns a copy of s with the log level s should be logged at stored under the "level"
+// key, in the same text form zap uses (e.g. "warn"). Any previous level of s is replaced
+func (s Structured) WithLevel(level zapcore.Level) Structured {
+	return s.SetField(zap.String(levelKey, level.String()))
+}
+
+// LevelOf returns the level set with WithLevel on err or an error in its cause chain. If several
+// errors in the chain have a level, the outermost one wins
+func LevelOf(err error) (level zapcore.Level, ok bool) {
+	f, ok := chainField(err, levelKey)
+	if !ok || f.Type != zapcore.StringType {
+		return zapcore.ErrorLevel, false
+	}
+	if err := level.UnmarshalText([]byte(f.String)); err != nil {
+		return zapcore.ErrorLevel, false
+	}
+	return level, true
+}
+
+// WithHTTPStatus returns a copy of s with the HTTP status code that s should be (or was) reported
+// as stored under the "httpStatus" key. Any previous status of s is replaced
+func (s Structured) WithHTTPStatus(status int) Structured {
+	return s.SetField(zap.Int(httpStatusKey, status))
+}
+
+// HTTPStatusOf returns the HTTP status set with WithHTTPStatus on err or an error in its cause
+// chain. If several errors in the chain have a status, the outermost one wins
+func HTTPStatusOf(err error) (status int, ok bool) {
+	f, ok := chainField(err, httpStatusKey)
+	if !ok || f.Type != zapcore.Int64Type {
+		return 0, false
+	}
+	return int(f.Integer), true
+}
+
+// WithAttempt returns a copy of s with the number of the attempt that failed, e.g. in a retry loop,
+// stored under the "attempt" key. Any previous attempt number of s is replaced
+func (s Structured) WithAttempt(n int) Structured {
+	return s.SetField(zap.Int(attemptKey, n))
+}
+
+// IncrementAttempt returns a copy of s with its attempt number set to one more than the one given
+// by AttemptOf(s), so that when each attempt wraps the error of the previous one, the attempt
+// numbers count up:
+// 	stre, _ := erreur.AsStructured(erreur.Wrap(lastErr, "fetch failed"))
+// 	lastErr = stre.IncrementAttempt()
+// Errors with no attempt in their chain get the attempt number 1
+func (s Structured) IncrementAttempt() Structured {
+	n, _ := AttemptOf(s)
+	return s.WithAttempt(n + 1)
+}
+
+// AttemptOf returns the attempt number set with WithAttempt on err or an error in its cause chain.
+// If several errors in the chain have one, the outermost, i.e. latest, one wins
+func AttemptOf(err error) (n int, ok bool) {
+	f, ok := chainField(err, attemptKey)
+	if !ok || f.Type != zapcore.Int64Type {
+		return 0, false
+	}
+	return int(f.Integer), true
+}
+
+// WithLatencySince returns a copy of s with the time elapsed since start stored as a duration under
+// the "latency" key, for errors of operations whose duration matters:
+// 	start := time.Now()
+// 	...
+// 	return stre.WithLatencySince(start)
+// The latency is computed when WithLatencySince is called, not when s is serialized. Any previous
+// latency of s is replaced
+func (s Structured) WithLatencySince(start time.Time) Structured {
+	return s.SetField(zap.Duration(latencyKey, now().Sub(start)))
+}
+
+// WithUser returns a copy of s with the ID of the user s concerns stored under the "userID" key.
+// The ID is personally identifiable information, so if RedactPII is set, it's serialized as a hash
+// of the ID instead of the ID itself. The hash is the same for the same ID, so errors of one user
+// can still be correlated. Any previous user ID of s is replaced
+func (s Structured) WithUser(userID string) Structured {
+	return s.SetField(zap.Stringer(userIDKey, piiString(userID)))
+}
+
+// QueryLimit is the number of bytes WithQuery keeps of longer queries
+const QueryLimit = 1024
+
+// WithQuery returns a copy of s with the database query that failed stored under the "query" key,
+// and the number of arguments it had under "queryArgCount":
+// 	{"msg":"insert failed","query":"INSERT INTO users (name) VALUES ($1)","queryArgCount":1}
+// Queries longer than QueryLimit bytes are truncated, with "..." appended. The arguments themselves
+// usually contain user data, so they're left out unless SetLogQueryArgs is on, in which case they're
+// stored under "queryArgs" as well. Any previous query of s is replaced
+func (s Structured) WithQuery(query string, args ...interface{}) Structured {
+	if len(query) > QueryLimit {
+		cut := QueryLimit
+		for cut > 0 && !utf8.RuneStart(query[cut]) {
+			cut--
+		}
+		query = query[:cut] + "..."
+	}
+	s = s.SetField(zap.String(queryKey, query)).SetField(zap.Int(argCountKey, len(args)))
+	if logQueryArgs {
+		s = s.SetField(zap.Reflect(queryArgsKey, args))
+	}
+	return s
+}
+
+// piiString is a string that is redacted when serialized if RedactPII is set
+type piiString string
+
+// String implements fmt.Stringer
+func (ps piiString) String() string {
+	if !RedactPII {
+		return string(ps)
+	}
+	sum := sha256.Sum256([]byte(ps))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// WithSource returns a copy of s with source, which names the component (e.g. a package or
+// subsystem) that produced s, stored under the "source" key. Any previous source of s is replaced
+func (s Structured) WithSource(source string) Structured {
+	return s.SetField(zap.String(sourceKey, source))
+}
+
+// SourceOf returns the source set with WithSource on err or an error in its cause chain. Unlike
+// CodeOf and OperationOf, the innermost source wins if several errors in the chain have one, since
+// that's the component the error originated from; the outer ones just passed it along
+func SourceOf(err error) (source string, ok bool) {
+	f, ok := rootChainField(err, sourceKey)
+	if !ok || f.Type != zapcore.StringType {
+		return "", false
+	}
+	return f.String, true
+}
+
+// tags is the value of the "tags" field
+type tags []string
+
+// MarshalLogArray implements zapcore.ArrayMarshaler
+func (ts tags) MarshalLogArray(ae zapcore.ArrayEncoder) error {
+	for _, t := range ts {
+		ae.AppendString(t)
+	}
+	return nil
+}
+
+func (ts tags) has(tag string) bool {
+	for _, t := range ts {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// WithTags returns a copy of s with the given tags added to the tags of s, which are stored as an
+// array under the "tags" key. Tags are flags like "retryable" or "user-facing" that classify an
+// error without needing a value. Tags s already has aren't added twice
+func (s Structured) WithTags(newTags ...string) Structured {
+	var ts tags
+	if f, ok := s.field(tagsKey); ok {
+		ts, _ = f.Interface.(tags)
+	}
+
+	merged := make(tags, len(ts), len(ts)+len(newTags))
+	copy(merged, ts)
+	for _, t := range newTags {
+		if !merged.has(t) {
+			merged = append(merged, t)
+		}
+	}
+	return s.SetField(zap.Array(tagsKey, merged))
+}
+
+// HasTag returns true if err or any error in its cause chain has the given tag
+func HasTag(err error, tag string) bool {
+	for err != nil {
+		if stre, ok := err.(Structured); ok {
+			if f, ok := stre.field(tagsKey); ok {
+				if ts, ok := f.Interface.(tags); ok && ts.has(tag) {
+					return true
+				}
+			}
+		}
+		cause, ok := err.(wrapper)
+		if !ok {
+			break
+		}
+		err = cause.Unwrap()
+	}
+	return false
+}
@@ -0,0 +1,424 @@
+package erreur
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestOperationOf(t *testing.T) {
+	inner := mustStructured(t, New("query failed", zap.String("table", "users"))).WithOperation("db.Query")
+	wrapped := fmt.Errorf("handler: %w", Wrap(inner, "loading user failed"))
+	overridden := mustStructured(t, Wrap(inner, "RPC failed")).WithOperation("UserService.GetUser")
+
+	tests := []struct {
+		name   string
+		err    error
+		wantOp string
+		wantOK bool
+	}{
+		{"own", inner, "db.Query", true},
+		{"through chain", wrapped, "db.Query", true},
+		{"outermost wins", overridden, "UserService.GetUser", true},
+		{"none", New("no op"), "", false},
+		{"plain", String("plain"), "", false},
+		{"nil", nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op, ok := OperationOf(tt.err)
+			if op != tt.wantOp || ok != tt.wantOK {
+				t.Errorf("got %q, %v, want %q, %v", op, ok, tt.wantOp, tt.wantOK)
+			}
+		})
+	}
+
+	const want = `{"msg":"query failed","table":"users","operation":"db.Query"}` + "\n"
+	if got := inner.JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExitCodeOf(t *testing.T) {
+	usage := mustStructured(t, New("unknown flag", zap.String("flag", "-x"))).WithExitCode(2)
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"own", usage, 2},
+		{"through chain", fmt.Errorf("parsing args: %w", Wrap(usage, "invalid usage")), 2},
+		{"outermost wins", mustStructured(t, Wrap(usage, "config")).WithExitCode(78), 78},
+		{"unset", New("failed"), 1},
+		{"plain", String("plain"), 1},
+		{"nil", nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCodeOf(tt.err); got != tt.want {
+				t.Errorf("got exit code %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	notFound := mustStructured(t, New("user not found", zap.Int("userID", 1234))).WithCode("USER_NOT_FOUND")
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode string
+		wantOK   bool
+	}{
+		{"own", notFound, "USER_NOT_FOUND", true},
+		{"through chain", fmt.Errorf("handler: %w", Wrap(notFound, "loading profile")), "USER_NOT_FOUND", true},
+		{"outermost wins", mustStructured(t, Wrap(notFound, "RPC failed")).WithCode("NOT_FOUND"), "NOT_FOUND", true},
+		{"integer code", New("connection error", zap.Int("code", 1234)), "1234", true},
+		{"other type", New("weird", zap.Bool("code", true)), "", false},
+		{"none", New("no code"), "", false},
+		{"nil", nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, ok := CodeOf(tt.err)
+			if code != tt.wantCode || ok != tt.wantOK {
+				t.Errorf("got %q, %v, want %q, %v", code, ok, tt.wantCode, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestStructured_Codes(t *testing.T) {
+	root := mustStructured(t, New("no rows")).WithCode("DB_NOT_FOUND")
+	mid := Wrap(fmt.Errorf("query: %w", root), "loading user", zap.Int("code", 404))
+	top := mustStructured(t, Wrap(mid, "request failed")).WithCode("DB_NOT_FOUND")
+
+	want := []string{"DB_NOT_FOUND", "404"}
+	if got := top.Codes(); !equalStrings(got, want) {
+		t.Errorf("got codes %v, want %v", got, want)
+	}
+
+	if got := mustStructured(t, Wrap(New("a"), "b")).Codes(); got != nil {
+		t.Errorf("got codes %v for a chain with no codes, want nil", got)
+	}
+}
+
+func TestHasCode(t *testing.T) {
+	root := mustStructured(t, New("no rows")).WithCode("DB_NOT_FOUND")
+	err := mustStructured(t, Wrap(fmt.Errorf("query: %w", root), "loading user")).WithCode("USER_NOT_FOUND")
+
+	for _, code := range []string{"USER_NOT_FOUND", "DB_NOT_FOUND"} {
+		if !HasCode(err, code) {
+			t.Errorf("HasCode didn't find %q", code)
+		}
+	}
+	if HasCode(err, "FORBIDDEN") || HasCode(nil, "DB_NOT_FOUND") {
+		t.Error("HasCode found a code that isn't there")
+	}
+}
+
+func TestErrorSet_Contains(t *testing.T) {
+	userErrors := NewErrorSet("USER_NOT_FOUND", "INVALID_INPUT", "FORBIDDEN")
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"own code", mustStructured(t, New("bad email")).WithCode("INVALID_INPUT"), true},
+		{"code of a cause", Wrap(mustStructured(t, New("no such user")).WithCode("USER_NOT_FOUND"), "request failed"), true},
+		{"other code", mustStructured(t, New("disk full")).WithCode("INTERNAL"), false},
+		{"integer code", New("weird", zap.Int("code", 403)), false},
+		{"no code", New("no code"), false},
+		{"plain", String("EOF"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := userErrors.Contains(tt.err); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructured_IncrementAttempt(t *testing.T) {
+	first := mustStructured(t, New("connection refused")).IncrementAttempt()
+	if n, ok := AttemptOf(first); n != 1 || !ok {
+		t.Errorf("got attempt %d, %v for the first attempt, want 1, true", n, ok)
+	}
+
+	var err error = first
+	for i := 0; i < 2; i++ {
+		err = mustStructured(t, Wrap(err, "fetch failed")).IncrementAttempt()
+	}
+	if n, _ := AttemptOf(err); n != 3 {
+		t.Errorf("got attempt %d after two re-wraps, want 3", n)
+	}
+	const want = `{"msg":"fetch failed","attempt":3,"cause":{"msg":"fetch failed","attempt":2,"cause":{"msg":"connection refused","attempt":1}}}` + "\n"
+	if got := mustStructured(t, err).JSON(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	if n, _ := AttemptOf(first.WithAttempt(7)); n != 7 {
+		t.Errorf("got attempt %d after WithAttempt(7)", n)
+	}
+	if _, ok := AttemptOf(New("no attempt")); ok {
+		t.Error("AttemptOf returned ok for an error with no attempt")
+	}
+}
+
+func TestStructured_WithLatencySince(t *testing.T) {
+	start := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	stre := mustStructured(t, New("slow query")).WithLatencySince(start)
+	time.Sleep(20 * time.Millisecond)
+
+	latency, ok := stre.DurationField("latency")
+	if !ok || latency < 10*time.Millisecond || latency >= time.Since(start) {
+		t.Errorf("got latency %v, %v, want at least 10ms and less than the time elapsed since", latency, ok)
+	}
+
+	called := time.Date(2019, 7, 1, 12, 30, 0, 0, time.UTC)
+	defer setNow(called)()
+	exact := mustStructured(t, New("slow query")).WithLatencySince(called.Add(-1500 * time.Millisecond))
+	if got := exact.JSON(); got != `{"msg":"slow query","latency":1.5}`+"\n" {
+		t.Errorf("got JSON %s", got)
+	}
+}
+
+func TestStructured_WithQuery(t *testing.T) {
+	const query = "SELECT * FROM users WHERE id = $1 AND org = $2"
+	base := mustStructured(t, New("query failed"))
+
+	const want = `{"msg":"query failed","query":"` + query + `","queryArgCount":2}` + "\n"
+	if got := base.WithQuery(query, 1234, "acme").JSON(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	SetLogQueryArgs(true)
+	defer SetLogQueryArgs(false)
+	const wantArgs = `{"msg":"query failed","query":"` + query + `","queryArgCount":2,"queryArgs":[1234,"acme"]}` + "\n"
+	if got := base.WithQuery(query, 1234, "acme").JSON(); got != wantArgs {
+		t.Errorf("got with SetLogQueryArgs on\n%s\nwant\n%s", got, wantArgs)
+	}
+
+	long := "SELECT '" + strings.Repeat("é", QueryLimit) + "'"
+	got, _ := base.WithQuery(long).StringField(queryKey)
+	if len(got) > QueryLimit+3 || !strings.HasSuffix(got, "é...") {
+		t.Errorf("long query wasn't truncated properly: %d bytes, ends with %q", len(got), got[len(got)-8:])
+	}
+}
+
+func TestReservedKeys(t *testing.T) {
+	want := []string{"...", "cause", "chain", "errors", "msg", "stacktrace"}
+	if got := ReservedKeys(); !equalStrings(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	SetNestedMessageKey("errorMsg")
+	defer SetNestedMessageKey("msg")
+	SetRecordTime(true)
+	defer SetRecordTime(false)
+	want = []string{"...", "cause", "chain", "errorMsg", "errors", "msg", "stacktrace", "ts"}
+	if got := ReservedKeys(); !equalStrings(got, want) {
+		t.Errorf("got %v with a custom message key and recorded times, want %v", got, want)
+	}
+
+	SetMaxOutputBytes(1000)
+	defer SetMaxOutputBytes(0)
+	want = []string{"...", "cause", "chain", "errorMsg", "errors", "msg", "stacktrace", "truncated", "ts"}
+	if got := ReservedKeys(); !equalStrings(got, want) {
+		t.Errorf("got %v with an output limit, want %v", got, want)
+	}
+}
+
+func TestCheckReservedKeys(t *testing.T) {
+	collides := Wrap(fmt.Errorf("db: %w", New("dial failed", zap.String("cause", "timeout"))), "query failed", zap.String("table", "users"))
+	err := CheckReservedKeys(collides)
+	if !errors.Is(err, ErrReservedKey) {
+		t.Fatalf("got %v, want ErrReservedKey", err)
+	}
+	if key, _ := mustStructured(t, err).StringField("key"); key != "cause" {
+		t.Errorf("got key %q, want %q", key, "cause")
+	}
+
+	SetNestedMessageKey("message")
+	defer SetNestedMessageKey("msg")
+	for _, key := range []string{"msg", "message"} {
+		if err := CheckReservedKeys(New("x", zap.String(key, "y"))); !errors.Is(err, ErrReservedKey) {
+			t.Errorf("got %v for a field named %q with a custom message key", err, key)
+		}
+		if _, err := NewStrict("x", zap.String(key, "y")); !errors.Is(err, ErrReservedKey) {
+			t.Errorf("NewStrict returned %v for a field named %q with a custom message key", err, key)
+		}
+	}
+
+	SetRecordTime(true)
+	defer SetRecordTime(false)
+	fine := Wrap(New("dial failed", zap.Namespace("retry"), zap.String("cause", "timeout")), "query failed", zap.String("code", "DB"))
+	if err := CheckReservedKeys(fine); err != nil {
+		t.Errorf("got %v for an error with no reserved keys", err)
+	}
+}
+
+func TestHasTag(t *testing.T) {
+	inner := mustStructured(t, New("rate limited")).WithTags("retryable", "user-facing")
+	outer := mustStructured(t, Wrap(inner, "request failed")).WithTags("external").WithTags("external", "critical")
+
+	const want = `{"msg":"request failed","tags":["external","critical"],"cause":{"msg":"rate limited","tags":["retryable","user-facing"]}}` + "\n"
+	if got := outer.JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		tag  string
+		want bool
+	}{
+		{"own", inner, "retryable", true},
+		{"outer", outer, "critical", true},
+		{"through chain", fmt.Errorf("handler: %w", outer), "user-facing", true},
+		{"absent", outer, "fatal", false},
+		{"no tags", New("no tags"), "retryable", false},
+		{"string field named tags", New("weird", zap.String("tags", "retryable")), "retryable", false},
+		{"nil", nil, "retryable", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasTag(tt.err, tt.tag); got != tt.want {
+				t.Errorf("got HasTag %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceOf(t *testing.T) {
+	storage := mustStructured(t, New("disk full", zap.String("path", "/var/data"))).WithSource("storage")
+	cache := mustStructured(t, Wrap(storage, "writing cache entry")).WithSource("cache")
+	api := mustStructured(t, Wrap(cache, "saving profile")).WithSource("api")
+
+	tests := []struct {
+		name       string
+		err        error
+		wantSource string
+		wantOK     bool
+	}{
+		{"own", storage, "storage", true},
+		{"innermost wins", api, "storage", true},
+		{"through chain", fmt.Errorf("handler: %w", Wrap(cache, "request failed")), "storage", true},
+		{"outer only", mustStructured(t, Wrap(String("eof"), "reading config")).WithSource("config"), "config", true},
+		{"none", New("no source"), "", false},
+		{"plain", String("plain"), "", false},
+		{"nil", nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, ok := SourceOf(tt.err)
+			if source != tt.wantSource || ok != tt.wantOK {
+				t.Errorf("got %q, %v, want %q, %v", source, ok, tt.wantSource, tt.wantOK)
+			}
+		})
+	}
+
+	const want = `{"msg":"writing cache entry","source":"cache","cause":{"msg":"disk full","path":"/var/data","source":"storage"}}` + "\n"
+	if got := cache.JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLevelOf(t *testing.T) {
+	warn := mustStructured(t, New("cache miss")).WithLevel(zapcore.WarnLevel)
+
+	tests := []struct {
+		name      string
+		err       error
+		wantLevel zapcore.Level
+		wantOK    bool
+	}{
+		{"own", warn, zapcore.WarnLevel, true},
+		{"through chain", fmt.Errorf("handler: %w", Wrap(warn, "loading user")), zapcore.WarnLevel, true},
+		{"outermost wins", mustStructured(t, Wrap(warn, "fatal")).WithLevel(zapcore.DPanicLevel), zapcore.DPanicLevel, true},
+		{"invalid", New("bad level", zap.String("level", "loud")), zapcore.ErrorLevel, false},
+		{"none", New("no level"), zapcore.ErrorLevel, false},
+		{"nil", nil, zapcore.ErrorLevel, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, ok := LevelOf(tt.err)
+			if level != tt.wantLevel || ok != tt.wantOK {
+				t.Errorf("got %v, %v, want %v, %v", level, ok, tt.wantLevel, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestHTTPStatusOf(t *testing.T) {
+	notFound := mustStructured(t, New("user not found")).WithHTTPStatus(http.StatusNotFound)
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantOK     bool
+	}{
+		{"own", notFound, 404, true},
+		{"through chain", fmt.Errorf("handler: %w", Wrap(notFound, "loading")), 404, true},
+		{"none", New("failed"), 0, false},
+		{"nil", nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, ok := HTTPStatusOf(tt.err)
+			if status != tt.wantStatus || ok != tt.wantOK {
+				t.Errorf("got %d, %v, want %d, %v", status, ok, tt.wantStatus, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestStructured_WithUser(t *testing.T) {
+	stre := mustStructured(t, New("permission denied", zap.String("resource", "invoice"))).WithUser("bob")
+
+	const wantPlain = `{"msg":"permission denied","resource":"invoice","userID":"bob"}` + "\n"
+	if got := stre.JSON(); got != wantPlain {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, wantPlain)
+	}
+
+	RedactPII = true
+	defer func() { RedactPII = false }()
+
+	const wantRedacted = `{"msg":"permission denied","resource":"invoice","userID":"sha256:81b637d8fcd2c6da"}` + "\n"
+	if got := stre.JSON(); got != wantRedacted {
+		t.Errorf("got redacted JSON\n%s\nwant\n%s", got, wantRedacted)
+	}
+	if got := mustStructured(t, New("other error")).WithUser("bob").JSON(); !strings.Contains(got, `"userID":"sha256:81b637d8fcd2c6da"`) {
+		t.Errorf("hash of the same user differs between errors: %s", got)
+	}
+	if got := mustStructured(t, New("other error")).WithUser("alice").JSON(); strings.Contains(got, "81b637d8fcd2c6da") {
+		t.Errorf("hash of a different user is the same: %s", got)
+	}
+
+	IncludeFieldsInError = true
+	defer func() { IncludeFieldsInError = false }()
+	if got := stre.Error(); strings.Contains(got, "bob") {
+		t.Errorf("Error() contains the user ID: %q", got)
+	}
+}
@@ -0,0 +1,51 @@
+package erreur
+
+import (
+	"net/http"
+)
+
+// RetryableTag is the tag (see WithTags) that marks an error as retryable for ShouldRetry
+const RetryableTag = "retryable"
+
+// retryPolicy is the policy used by ShouldRetry
+var retryPolicy = DefaultRetryPolicy
+
+// DefaultRetryPolicy is the retry policy ShouldRetry uses unless SetRetryPolicy has been called. It
+// returns true if any of the following is true:
+//   - s or an error in its cause chain is temporary (see Structured.Temporary)
+//   - s or an error in its cause chain has RetryableTag
+//   - the HTTP status of s (see HTTPStatusOf) is 429 Too Many Requests or 503 Service Unavailable
+func DefaultRetryPolicy(s Structured) bool {
+	if s.Temporary() || HasTag(s, RetryableTag) {
+		return true
+	}
+	status, _ := HTTPStatusOf(s)
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+}
+
+// SetRetryPolicy sets the function ShouldRetry uses to decide whether an operation that failed with
+// an error should be retried. Non-structured errors are passed to it as if they were wrapped with
+// Structure. Custom policies can build on DefaultRetryPolicy, e.g.
+// 	erreur.SetRetryPolicy(func(s erreur.Structured) bool {
+// 		return s.Timeout() || erreur.DefaultRetryPolicy(s)
+// 	})
+// A nil policy restores DefaultRetryPolicy.
+func SetRetryPolicy(policy func(Structured) bool) {
+	if policy == nil {
+		policy = DefaultRetryPolicy
+	}
+	retryPolicy = policy
+}
+
+// ShouldRetry returns true if the operation that failed with err should be retried according to the
+// policy set with SetRetryPolicy, which is DefaultRetryPolicy by default. Returns false if err is nil
+func ShouldRetry(err error) bool {
+	if err == nil {
+		return false
+	}
+	stre, ok := err.(Structured)
+	if !ok {
+		stre = Structured{causer: err}
+	}
+	return retryPolicy(stre)
+}
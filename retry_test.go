@@ -0,0 +1,69 @@
+package erreur
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestShouldRetry(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"temporary", Wrap(fakeNetError{temporary: true}, "dial failed"), true},
+		{"plain temporary", fakeNetError{temporary: true}, true},
+		{"temporary through fmt", fmt.Errorf("query: %w", Wrap(fakeNetError{temporary: true}, "dial failed")), true},
+		{"timeout only", Wrap(fakeNetError{timeout: true}, "dial failed"), false},
+		{"tag", mustStructured(t, New("conflict")).WithTags(RetryableTag), true},
+		{"tag in cause", Wrap(mustStructured(t, New("conflict")).WithTags(RetryableTag), "saving"), true},
+		{"other tag", mustStructured(t, New("conflict")).WithTags("user-facing"), false},
+		{"status 429", mustStructured(t, New("rate limited")).WithHTTPStatus(http.StatusTooManyRequests), true},
+		{"status 503", Wrap(mustStructured(t, New("unavailable")).WithHTTPStatus(http.StatusServiceUnavailable), "calling API"), true},
+		{"status 500", mustStructured(t, New("internal")).WithHTTPStatus(http.StatusInternalServerError), false},
+		{"outer status wins", mustStructured(t, Wrap(mustStructured(t, New("unavailable")).WithHTTPStatus(503), "bad")).WithHTTPStatus(400), false},
+		{"nothing", New("failed", zap.Int("code", 1)), false},
+		{"plain", String("plain"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShouldRetry(tt.err); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetRetryPolicy(t *testing.T) {
+	defer SetRetryPolicy(nil)
+
+	SetRetryPolicy(func(s Structured) bool {
+		return s.Timeout() || DefaultRetryPolicy(s)
+	})
+	if !ShouldRetry(Wrap(fakeNetError{timeout: true}, "dial failed")) {
+		t.Error("custom policy didn't retry a timeout")
+	}
+	if !ShouldRetry(mustStructured(t, New("conflict")).WithTags(RetryableTag)) {
+		t.Error("custom policy didn't fall back to the default")
+	}
+
+	var got Structured
+	SetRetryPolicy(func(s Structured) bool {
+		got = s
+		return false
+	})
+	_ = ShouldRetry(String("plain"))
+	if got.Unwrap() != String("plain") {
+		t.Errorf("plain error was passed to the policy as %#v", got)
+	}
+
+	SetRetryPolicy(nil)
+	if ShouldRetry(Wrap(fakeNetError{timeout: true}, "dial failed")) {
+		t.Error("SetRetryPolicy(nil) didn't restore the default policy")
+	}
+}
@@ -0,0 +1,123 @@
+package erreur
+
+import (
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// sampledCountKey is the key of the field Sampler adds for suppressed occurrences of an error
+const sampledCountKey = "sampledCount"
+
+// Sampler limits how often the same error gets logged, to keep a repeating error from flooding the
+// logs. Errors are considered the same if they have the same code (see CodeOf), or the same message
+// if they have no code. Of the errors with the same key, only the first n in each interval are let
+// through; the rest are suppressed and counted, and the first error let through in the next interval
+// that has any carries the count of suppressed errors in a "sampledCount" field.
+//
+// Keys are remembered for the lifetime of the Sampler, so it shouldn't be used with errors that have
+// unbounded numbers of different messages and no code.
+//
+// A Sampler is safe for concurrent use.
+type Sampler struct {
+	n        int
+	interval time.Duration
+
+	mu      sync.Mutex
+	windows map[string]*sampleWindow
+}
+
+// sampleWindow tracks the errors with one key during one interval
+type sampleWindow struct {
+	start      time.Time
+	seen       int // errors seen during the interval, including suppressed ones
+	suppressed int // errors suppressed during the previous interval, to be reported
+}
+
+// NewSampler returns a Sampler that lets through the first n errors with the same key in each
+// interval
+func NewSampler(n int, interval time.Duration) *Sampler {
+	return &Sampler{n: n, interval: interval, windows: make(map[string]*sampleWindow)}
+}
+
+// Field returns a field for err like the package-level Field function does, and ok=true if err
+// should be logged. If err has been sampled out, it returns a no-op field and ok=false, so callers
+// can skip logging it entirely:
+// 	if f, ok := sampler.Field(err); ok {
+// 		logger.Error("request failed", f)
+// 	}
+// A nil err is never sampled out
+func (sm *Sampler) Field(err error) (zapcore.Field, bool) {
+	if err == nil {
+		return zap.Skip(), true
+	}
+
+	suppressed, ok := sm.sample(sampleKey(err))
+	if !ok {
+		return zap.Skip(), false
+	}
+	if suppressed == 0 {
+		return Field(err), true
+	}
+
+	countField := zap.Int(sampledCountKey, suppressed)
+	if stre, isStructured := err.(Structured); isStructured {
+		return Field(stre.SetField(countField)), true
+	}
+	return Field(Structure(err, countField)), true
+}
+
+// sample records an occurrence of an error with key, and returns whether it should be let through
+// along with the number of suppressed errors to report with it
+func (sm *Sampler) sample(key string) (suppressed int, ok bool) {
+	t := now()
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	w, exists := sm.windows[key]
+	if !exists {
+		w = &sampleWindow{start: t}
+		sm.windows[key] = w
+	} else if t.Sub(w.start) >= sm.interval {
+		if w.seen > sm.n {
+			w.suppressed += w.seen - sm.n
+		}
+		w.start, w.seen = t, 0
+	}
+
+	w.seen++
+	if w.seen > sm.n {
+		return 0, false
+	}
+	suppressed, w.suppressed = w.suppressed, 0
+	return suppressed, true
+}
+
+// sampleKey returns the key Sampler groups err by
+func sampleKey(err error) string {
+	if code, ok := CodeOf(err); ok {
+		return "code:" + code
+	}
+	return "msg:" + err.Error()
+}
+
+// SamplingKey returns a key that's the same for identical errors, for grouping them in e.g. a
+// custom sampling zapcore.Core built like zapcore.NewSamplerWithOptions, which groups log entries by
+// their message. Errors with a code (see CodeOf) are grouped by code, so the key is "code:" followed
+// by the code. Other errors are grouped by their Canonical form, i.e. by their messages and field
+// values regardless of field order, and the key is "canonical:" followed by a 64-bit hash of it.
+// Unlike with Sampler, which groups errors with no code by message, errors with different field
+// values get different keys
+func (s Structured) SamplingKey() string {
+	if code, ok := CodeOf(s); ok {
+		return "code:" + code
+	}
+	h := fnv.New64a()
+	h.Write([]byte(s.Canonical()))
+	return "canonical:" + strconv.FormatUint(h.Sum64(), 16)
+}
@@ -0,0 +1,106 @@
+package erreur
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestSampler(t *testing.T) {
+	start := time.Date(2019, 7, 1, 12, 30, 0, 0, time.UTC)
+	defer setNow(start)()
+
+	sm := NewSampler(2, time.Second)
+	newErr := func() error { return New("db down", zap.String("host", "db")) }
+
+	var passed int
+	for i := 0; i < 10; i++ {
+		if _, ok := sm.Field(newErr()); ok {
+			passed++
+		}
+	}
+	if passed != 2 {
+		t.Errorf("%d errors passed during the first interval, want 2", passed)
+	}
+
+	if _, ok := sm.Field(String("other error")); !ok {
+		t.Error("an error with a different message was sampled out")
+	}
+
+	setNow(start.Add(1500 * time.Millisecond))
+	f, ok := sm.Field(newErr())
+	if !ok {
+		t.Fatal("first error of the next interval was sampled out")
+	}
+	const want = `{"msg":"failed to load data","error":{"msg":"db down","host":"db","sampledCount":8}}` + "\n"
+	if got := logFields(f); got != want {
+		t.Errorf("got log line\n%s\nwant\n%s", got, want)
+	}
+
+	f, ok = sm.Field(newErr())
+	const wantNoCount = `{"msg":"failed to load data","error":{"msg":"db down","host":"db"}}` + "\n"
+	if got := logFields(f); !ok || got != wantNoCount {
+		t.Errorf("second error of the next interval got %v, log line\n%s\nwant\n%s", ok, got, wantNoCount)
+	}
+	if _, ok := sm.Field(newErr()); ok {
+		t.Error("third error of the next interval wasn't sampled out")
+	}
+}
+
+func TestSampler_keys(t *testing.T) {
+	defer setNow(time.Date(2019, 7, 1, 12, 30, 0, 0, time.UTC))()
+	sm := NewSampler(1, time.Minute)
+
+	if _, ok := sm.Field(mustStructured(t, New("user 1 not found")).WithCode("USER_NOT_FOUND")); !ok {
+		t.Error("first error with a code was sampled out")
+	}
+	if _, ok := sm.Field(mustStructured(t, New("user 2 not found")).WithCode("USER_NOT_FOUND")); ok {
+		t.Error("error with the same code but a different message wasn't sampled out")
+	}
+	if _, ok := sm.Field(New("user 2 not found")); !ok {
+		t.Error("error without a code was sampled out by an error with one")
+	}
+	if f, ok := sm.Field(nil); !ok || !f.Equals(zap.Skip()) {
+		t.Errorf("Field(nil) returned %v, %v", f, ok)
+	}
+}
+
+func TestSampler_plainCount(t *testing.T) {
+	start := time.Date(2019, 7, 1, 12, 30, 0, 0, time.UTC)
+	defer setNow(start)()
+	sm := NewSampler(1, time.Second)
+
+	sm.Field(String("disk full"))
+	sm.Field(String("disk full"))
+	setNow(start.Add(time.Second))
+
+	f, _ := sm.Field(String("disk full"))
+	const want = `{"msg":"failed to load data","error":{"msg":"disk full","sampledCount":1}}` + "\n"
+	if got := logFields(f); got != want {
+		t.Errorf("got log line\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestStructured_SamplingKey(t *testing.T) {
+	a := mustStructured(t, Wrap(New("dial failed"), "query failed", zap.String("table", "users"), zap.Int("port", 5432)))
+	b := mustStructured(t, Wrap(New("dial failed"), "query failed", zap.Int("port", 5432), zap.String("table", "users")))
+	if a.SamplingKey() != b.SamplingKey() {
+		t.Errorf("identical errors have different keys %q and %q", a.SamplingKey(), b.SamplingKey())
+	}
+	if !strings.HasPrefix(a.SamplingKey(), "canonical:") {
+		t.Errorf("got key %q for an error with no code", a.SamplingKey())
+	}
+
+	other := mustStructured(t, Wrap(New("dial failed"), "query failed", zap.String("table", "orgs"), zap.Int("port", 5432)))
+	if a.SamplingKey() == other.SamplingKey() {
+		t.Error("errors with different fields have the same key")
+	}
+
+	coded := mustStructured(t, Wrap(mustStructured(t, New("no rows", zap.Int("id", 1))).WithCode("NOT_FOUND"), "loading user"))
+	otherCoded := mustStructured(t, New("user missing", zap.Int("id", 2))).WithCode("NOT_FOUND")
+	if got := coded.SamplingKey(); got != "code:NOT_FOUND" || got != otherCoded.SamplingKey() {
+		t.Errorf("got keys %q and %q for errors with a code, want code:NOT_FOUND", got, otherCoded.SamplingKey())
+	}
+}
@@ -0,0 +1,51 @@
+package erreur
+
+import (
+	"encoding/json"
+)
+
+// JSONSchema returns a JSON Schema (draft 2020-12) describing the JSON that JSON(), MarshalJSON and
+// the other JSON functions produce, for consumers that validate errors or generate typed clients
+// for them. The schema reflects the current configuration: the key of nested messages set with
+// SetNestedMessageKey, and "chain" arrays instead of "cause" objects if SetChainAsArray is on. The
+// fields of errors are arbitrary, so the schema allows additional properties of any type
+func JSONSchema() []byte {
+	// the top-level message is the encoder's entry message, which is always "msg"
+	top := errorSchema("msg")
+	top["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	top["title"] = "erreur structured error"
+	top["$defs"] = map[string]interface{}{"error": errorSchema(nestedMessageKey)}
+
+	b, err := json.Marshal(top)
+	if err != nil {
+		panic("erreur: marshaling the JSON schema failed: " + err.Error())
+	}
+	return b
+}
+
+// errorSchema returns the schema of a serialized error object with the given message key
+func errorSchema(msgKey string) map[string]interface{} {
+	errorRef := map[string]interface{}{"$ref": "#/$defs/error"}
+
+	props := map[string]interface{}{
+		msgKey:        map[string]interface{}{"type": "string", "description": "the message of the error"},
+		"errors":      map[string]interface{}{"type": "array", "items": errorRef, "description": "the errors of a multi-error"},
+		"stacktrace":  map[string]interface{}{"type": "string", "description": "the call stack where the error was created"},
+		moreCausesKey: map[string]interface{}{"type": "string", "description": "marks causes left out of the output"},
+	}
+	if maxOutputBytes > 0 {
+		props[truncatedKey] = map[string]interface{}{"type": "boolean", "description": "marks fields left out to keep the output under the size limit"}
+	}
+	if chainAsArray {
+		props["chain"] = map[string]interface{}{"type": "array", "items": errorRef, "description": "the causes of the error, outermost first"}
+	} else {
+		props["cause"] = map[string]interface{}{"$ref": "#/$defs/error", "description": "the cause of the error"}
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"required":             []string{msgKey},
+		"properties":           props,
+		"additionalProperties": true,
+	}
+}
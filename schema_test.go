@@ -0,0 +1,70 @@
+package erreur
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// schemaShape is the part of the schema the tests look at
+type schemaShape struct {
+	Schema     string                     `json:"$schema"`
+	Type       string                     `json:"type"`
+	Required   []string                   `json:"required"`
+	Properties map[string]json.RawMessage `json:"properties"`
+	Defs       map[string]struct {
+		Required   []string                   `json:"required"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	} `json:"$defs"`
+}
+
+func decodeSchema(t *testing.T) schemaShape {
+	t.Helper()
+	var shape schemaShape
+	if err := json.Unmarshal(JSONSchema(), &shape); err != nil {
+		t.Fatalf("the schema isn't valid JSON: %v", err)
+	}
+	return shape
+}
+
+func TestJSONSchema(t *testing.T) {
+	shape := decodeSchema(t)
+	if shape.Schema != "https://json-schema.org/draft/2020-12/schema" || shape.Type != "object" {
+		t.Errorf("got $schema %q and type %q", shape.Schema, shape.Type)
+	}
+	if !equalStrings(shape.Required, []string{"msg"}) {
+		t.Errorf("got required %v, want [msg]", shape.Required)
+	}
+	for _, key := range []string{"msg", "cause", "errors", "stacktrace"} {
+		if _, ok := shape.Properties[key]; !ok {
+			t.Errorf("top-level property %q missing", key)
+		}
+	}
+	if got := string(shape.Properties["cause"]); got != `{"$ref":"#/$defs/error","description":"the cause of the error"}` {
+		t.Errorf("got cause property %s", got)
+	}
+	if def, ok := shape.Defs["error"]; !ok || !equalStrings(def.Required, []string{"msg"}) {
+		t.Errorf("got error definition %+v", shape.Defs)
+	}
+}
+
+func TestJSONSchema_options(t *testing.T) {
+	SetNestedMessageKey("errorMsg")
+	defer SetNestedMessageKey("msg")
+	SetChainAsArray(true)
+	defer SetChainAsArray(false)
+
+	shape := decodeSchema(t)
+	if !equalStrings(shape.Required, []string{"msg"}) {
+		t.Errorf("got top-level required %v, want [msg]", shape.Required)
+	}
+	def := shape.Defs["error"]
+	if _, ok := def.Properties["errorMsg"]; !ok || !equalStrings(def.Required, []string{"errorMsg"}) {
+		t.Errorf("the nested message key isn't used: %+v", def)
+	}
+	if _, ok := shape.Properties["chain"]; !ok {
+		t.Error("chain property missing with SetChainAsArray on")
+	}
+	if _, ok := shape.Properties["cause"]; ok {
+		t.Error("cause property present with SetChainAsArray on")
+	}
+}
@@ -0,0 +1,53 @@
+package erreur
+
+import (
+	"io"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Serializer serializes structured errors to JSON, producing the same output as
+// Structured.MarshalJSON. Unlike MarshalJSON, which creates a new JSON encoder for every call, a
+// Serializer creates its encoder once and reuses it, so it's cheaper when serializing lots of
+// errors, e.g. in a loop.
+//
+// A Serializer is safe for concurrent use.
+type Serializer struct {
+	enc zapcore.Encoder
+}
+
+// NewSerializer returns a new Serializer
+func NewSerializer() *Serializer {
+	return &Serializer{enc: zapcore.NewJSONEncoder(jsonEncConf)}
+}
+
+// Serialize returns the JSON serialization of s
+func (sz *Serializer) Serialize(s Structured) []byte {
+	return copyAndFree(s.encodeJSON(sz.enc))
+}
+
+// WriteNDJSON writes errs to w as newline-delimited JSON, i.e. one JSON object per line, for bulk
+// error dumps. Structured errors are serialized like with MarshalJSON, and other errors as an object
+// with just their message, like {"msg":"EOF"}. nil errors are skipped. Returns the number of bytes
+// written, and stops at the first error writing to w
+func WriteNDJSON(w io.Writer, errs ...error) (int, error) {
+	sz := NewSerializer()
+	total := 0
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		stre, ok := liftStructured(err)
+		if !ok {
+			stre = Structured{causer: err}
+		}
+		buf := stre.encodeJSON(sz.enc)
+		n, werr := w.Write(buf.Bytes())
+		buf.Free()
+		total += n
+		if werr != nil {
+			return total, werr
+		}
+	}
+	return total, nil
+}
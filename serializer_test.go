@@ -0,0 +1,112 @@
+package erreur
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSerializer_Serialize(t *testing.T) {
+	sz := NewSerializer()
+	errs := []Structured{
+		mustStructured(t, New("connection error", zap.Int("code", 1234))),
+		mustStructured(t, Wrap(New("inner", zap.String("k", "v")), "outer", zap.Bool("b", true))),
+		mustStructured(t, Structure(String("plain"), zap.Int("n", 1))),
+	}
+
+	var wg sync.WaitGroup
+	for _, stre := range errs {
+		want, _ := stre.MarshalJSON()
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(stre Structured) {
+				defer wg.Done()
+				if got := sz.Serialize(stre); string(got) != string(want) {
+					t.Errorf("got\n%s\nwant\n%s", got, want)
+				}
+			}(stre)
+		}
+	}
+	wg.Wait()
+}
+
+var benchBytes []byte
+var benchString string
+
+func BenchmarkSerializer(b *testing.B) {
+	stre, _ := AsStructured(Wrap(New("inner", zap.String("k", "v"), zap.Int("n", 1)), "outer", zap.Bool("b", true)))
+
+	b.Run("Serializer.Serialize", func(b *testing.B) {
+		sz := NewSerializer()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			benchBytes = sz.Serialize(stre)
+		}
+	})
+
+	b.Run("Structured.JSON", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			benchString = stre.JSON()
+		}
+	})
+}
+
+type failingWriter struct{ after int }
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	if fw.after == 0 {
+		return 0, io.ErrShortWrite
+	}
+	fw.after--
+	return len(p), nil
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := WriteNDJSON(&buf,
+		New("connection error", zap.Int("code", 1234)),
+		nil,
+		io.EOF,
+		fmt.Errorf("handler: %w", New("query failed", zap.String("table", "users"))),
+		Structure(String("line\nbreak"), zap.Int("n", 1)),
+	)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if n != buf.Len() {
+		t.Errorf("returned %d bytes written, but wrote %d", n, buf.Len())
+	}
+
+	wantLines := []string{
+		`{"msg":"connection error","code":1234}`,
+		`{"msg":"EOF"}`,
+		`{"msg":"handler","cause":{"msg":"query failed","table":"users"}}`,
+		`{"msg":"line\nbreak","n":1}`,
+	}
+	gotLines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if !equalStrings(gotLines, wantLines) {
+		t.Errorf("got lines\n%s\nwant\n%s", strings.Join(gotLines, "\n"), strings.Join(wantLines, "\n"))
+	}
+	for i, line := range gotLines {
+		if !json.Valid([]byte(line)) {
+			t.Errorf("line %d isn't valid JSON: %s", i, line)
+		}
+	}
+}
+
+func TestWriteNDJSON_writeError(t *testing.T) {
+	n, err := WriteNDJSON(&failingWriter{after: 1}, New("first"), New("second"), New("third"))
+	if err != io.ErrShortWrite {
+		t.Errorf("got error %v, want %v", err, io.ErrShortWrite)
+	}
+	if want := len(`{"msg":"first"}` + "\n"); n != want {
+		t.Errorf("got %d bytes written, want %d", n, want)
+	}
+}
@@ -0,0 +1,186 @@
+package erreur
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	pkgerrors "github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// StackTracer is implemented by errors that carry the call stack of where they were created, as
+// program counters like the ones returned by runtime.Callers
+type StackTracer interface {
+	StackTrace() []uintptr
+}
+
+// pkgStackTracer is implemented by the errors of https://github.com/pkg/errors that carry a stack
+type pkgStackTracer interface {
+	StackTrace() pkgerrors.StackTrace
+}
+
+// pkgStack adapts a pkg/errors error with a stack to StackTracer
+type pkgStack struct {
+	pkgStackTracer
+}
+
+// StackTrace implements StackTracer. The frames of pkg/errors are the program counters returned by
+// runtime.Callers, so they're converted as-is
+func (ps pkgStack) StackTrace() []uintptr {
+	frames := ps.pkgStackTracer.StackTrace()
+	pcs := make([]uintptr, len(frames))
+	for i, f := range frames {
+		pcs[i] = uintptr(f)
+	}
+	return pcs
+}
+
+// StackEnvVar is the environment variable that enables stack capture in New and Wrap. If it's set
+// to a true value like "1" or "true" when the program starts, New and Wrap capture the call stack
+// like WrapWithStack does, so that stacks can be turned on for debugging e.g. a production incident
+// without code changes.
+//
+// Capturing a stack is considerably more expensive than creating an error without one (it costs
+// roughly a microsecond and a 512 byte allocation per error, and serializing the stack costs more),
+// so this isn't meant to be left on in code that creates lots of errors.
+const StackEnvVar = "ERREUR_STACK"
+
+// captureStacks is true if New and Wrap should capture stacks
+var captureStacks = stacksEnabled(os.Getenv)
+
+// stacksEnabled returns true if the StackEnvVar environment variable, as returned by getenv, is set
+// to a true value
+func stacksEnabled(getenv func(string) string) bool {
+	enabled, _ := strconv.ParseBool(getenv(StackEnvVar))
+	return enabled
+}
+
+// stackDepth is the maximum number of frames captured
+const stackDepth = 64
+
+// stack is a captured call stack
+type stack []uintptr
+
+// callers captures the stack of the caller of the function calling callers, skipping skip more
+// frames
+func callers(skip int) stack {
+	pcs := make([]uintptr, stackDepth)
+	n := runtime.Callers(skip+3, pcs)
+	return pcs[:n:n]
+}
+
+// String formats st like zap formats stack traces: one function per line, followed by its file and
+// line indented with a tab
+func (st stack) String() string {
+	var sb strings.Builder
+	frames := runtime.CallersFrames(st)
+	for {
+		frame, more := frames.Next()
+		if sb.Len() > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(frame.Function)
+		sb.WriteString("\n\t")
+		sb.WriteString(frame.File)
+		sb.WriteByte(':')
+		sb.WriteString(strconv.Itoa(frame.Line))
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// StackTrace implements StackTracer. It returns nil if no stack was captured for s
+func (s Structured) StackTrace() []uintptr {
+	return s.stack
+}
+
+// hasStack returns true if err or any error in its cause chain has a captured stack
+func hasStack(err error) bool {
+	return stackTracerOf(err) != nil
+}
+
+// WrapWithStack is like Wrap, but also captures the call stack, serialized under the "stacktrace"
+// key. To avoid the same stack showing up several times, the stack is only captured if no error in
+// the cause chain already has one, so when each layer of an application uses WrapWithStack, only
+// the innermost wrap carries a stack. Returns nil if cause is nil
+func WrapWithStack(cause error, message string, fields ...zap.Field) error {
+	if cause == nil {
+		return nil
+	}
+	s := Structured{causer: cause, err: String(message), fields: withCreationFields(fields)}
+	if !hasStack(cause) {
+		s.stack = callers(0)
+	}
+	return s
+}
+
+// panicMessage is the message of errors created by FromPanic
+const panicMessage = "panic"
+
+// FromPanic returns a structured error for a value recovered from a panic, with the message
+// "panic" and the given fields:
+// 	defer func() {
+// 		if v := recover(); v != nil {
+// 			err = erreur.FromPanic(v, zap.String("handler", name))
+// 		}
+// 	}()
+// If v is an error, it's the cause of the returned error, and otherwise the cause is an error with
+// v formatted with fmt.Sprint as its message. Returns nil if v is nil.
+//
+// The call stack is always captured, unless the panic value already carries one: if v or an error
+// in its cause chain implements StackTracer, like errors created with WrapWithStack do, or has a
+// pkg/errors stack, like errors created with pkg/errors' New and Wrap do, that stack is kept
+// instead, since it shows where the error was created rather than where the panic was recovered. A
+// stack captured by FromPanic in a deferred function still includes the function that panicked, as
+// deferred functions run on top of the panicking goroutine's stack
+func FromPanic(v interface{}, fields ...zap.Field) error {
+	if v == nil {
+		return nil
+	}
+	cause, ok := v.(error)
+	if !ok {
+		cause = String(fmt.Sprint(v))
+	}
+
+	s := Structured{causer: cause, err: String(panicMessage), fields: withCreationFields(fields)}
+	switch st := stackTracerOf(cause).(type) {
+	case nil:
+		s.stack = callers(0)
+	case Structured:
+		// the stack is already serialized with the cause
+	default:
+		s.stack = stack(st.StackTrace())
+	}
+	return s
+}
+
+// stackTracerOf returns the first error in err's cause chain that has a stack, or nil if none does.
+// Like RootCause, it follows both Unwrap() error and pkg/errors' Cause() error
+func stackTracerOf(err error) StackTracer {
+	for err != nil {
+		switch st := err.(type) {
+		case StackTracer:
+			if len(st.StackTrace()) > 0 {
+				return st
+			}
+		case pkgStackTracer:
+			if len(st.StackTrace()) > 0 {
+				return pkgStack{st}
+			}
+		}
+		switch e := err.(type) {
+		case wrapper:
+			err = e.Unwrap()
+		case interface{ Cause() error }:
+			err = e.Cause()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
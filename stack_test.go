@@ -0,0 +1,173 @@
+package erreur
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+func TestWrapWithStack(t *testing.T) {
+	inner := WrapWithStack(String("insufficient permissions"), "writing to file failed", zap.String("fileName", "f"))
+	outer := WrapWithStack(fmt.Errorf("layer: %w", inner), "failed to flush db")
+
+	innerStre := mustStructured(t, inner)
+	if len(innerStre.StackTrace()) == 0 {
+		t.Fatal("innermost wrap has no stack")
+	}
+	frames := innerStre.stack.String()
+	if !strings.HasPrefix(frames, "github.com/ORBAT/erreur.TestWrapWithStack\n\t") {
+		t.Errorf("stack doesn't start at the caller of WrapWithStack:\n%s", frames)
+	}
+
+	outerStre := mustStructured(t, outer)
+	if st := outerStre.StackTrace(); st != nil {
+		t.Errorf("outer wrap captured a stack even though its cause had one:\n%s", stack(st))
+	}
+
+	direct := mustStructured(t, WrapWithStack(WrapWithStack(inner, "middle"), "outer"))
+	if n := strings.Count(direct.JSON(), `"stacktrace":`); n != 1 {
+		t.Errorf("got %d stack traces in the JSON, want 1: %s", n, direct.JSON())
+	}
+	if !strings.Contains(innerStre.JSON(), `"fileName":"f","stacktrace":"github.com/ORBAT/erreur.TestWrapWithStack\n\t`) {
+		t.Errorf("stack trace missing or misplaced in JSON: %s", innerStre.JSON())
+	}
+}
+
+func TestWrapWithStack_nil(t *testing.T) {
+	if err := WrapWithStack(nil, "message"); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+}
+
+func TestWrap_noStack(t *testing.T) {
+	if st := mustStructured(t, Wrap(String("cause"), "message")).StackTrace(); st != nil {
+		t.Errorf("Wrap captured a stack:\n%s", stack(st))
+	}
+}
+
+func TestStacksEnabled(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"", false},
+		{"0", false},
+		{"false", false},
+		{"nonsense", false},
+		{"1", true},
+		{"true", true},
+	}
+
+	for _, tt := range tests {
+		getenv := func(key string) string {
+			if key != StackEnvVar {
+				t.Errorf("looked up %q, want %q", key, StackEnvVar)
+			}
+			return tt.value
+		}
+		if got := stacksEnabled(getenv); got != tt.want {
+			t.Errorf("%s=%q: got %v, want %v", StackEnvVar, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNew_captureStacks(t *testing.T) {
+	defer func(orig bool) { captureStacks = orig }(captureStacks)
+
+	captureStacks = false
+	if st := mustStructured(t, New("no stack")).StackTrace(); st != nil {
+		t.Errorf("New captured a stack with capture disabled:\n%s", stack(st))
+	}
+
+	captureStacks = true
+	leaf := mustStructured(t, New("with stack"))
+	if frames := leaf.stack.String(); !strings.HasPrefix(frames, "github.com/ORBAT/erreur.TestNew_captureStacks\n\t") {
+		t.Errorf("New's stack doesn't start at its caller:\n%s", frames)
+	}
+	if st := mustStructured(t, Wrap(leaf, "wrapped")).StackTrace(); st != nil {
+		t.Errorf("Wrap captured a stack even though its cause had one:\n%s", stack(st))
+	}
+	wrapped := mustStructured(t, Wrap(String("plain"), "wrapped"))
+	if frames := wrapped.stack.String(); !strings.HasPrefix(frames, "github.com/ORBAT/erreur.TestNew_captureStacks\n\t") {
+		t.Errorf("Wrap's stack doesn't start at its caller:\n%s", frames)
+	}
+}
+
+// stackError is a third-party-style error that carries its own stack
+type stackError struct {
+	msg   string
+	stack stack
+}
+
+func (se stackError) Error() string         { return se.msg }
+func (se stackError) StackTrace() []uintptr { return se.stack }
+
+func newStackError(msg string) stackError {
+	return stackError{msg: msg, stack: callers(0)}
+}
+
+// recoverFrom calls f and returns FromPanic of what it panics with
+func recoverFrom(f func()) (err error) {
+	defer func() {
+		err = FromPanic(recover(), zap.String("handler", "test"))
+	}()
+	f()
+	return nil
+}
+
+func TestFromPanic(t *testing.T) {
+	thirdParty := fmt.Errorf("lib: %w", newStackError("invariant violated"))
+	stre := mustStructured(t, recoverFrom(func() { panic(thirdParty) }))
+	if stre.Unwrap() != thirdParty || stre.Message() != "panic" {
+		t.Errorf("got cause %v and message %q", stre.Unwrap(), stre.Message())
+	}
+	if got := stre.stack.String(); !strings.HasPrefix(got, "github.com/ORBAT/erreur.TestFromPanic\n\t") {
+		t.Errorf("the panic value's stack wasn't kept, got:\n%s", got)
+	}
+
+	withStack := WrapWithStack(String("EOF"), "reading failed")
+	stre = mustStructured(t, recoverFrom(func() { panic(withStack) }))
+	if stre.StackTrace() != nil {
+		t.Errorf("captured a stack even though the structured cause has one:\n%s", stre.stack)
+	}
+	if n := strings.Count(stre.JSON(), `"stacktrace":`); n != 1 {
+		t.Errorf("got %d stack traces in the JSON, want 1: %s", n, stre.JSON())
+	}
+
+	stre = mustStructured(t, recoverFrom(func() { panic(42) }))
+	if got := stre.Error(); got != "panic: 42" {
+		t.Errorf("got Error() %q, want %q", got, "panic: 42")
+	}
+	if got := stre.stack.String(); !strings.Contains(got, "github.com/ORBAT/erreur.TestFromPanic.func3\n\t") {
+		t.Errorf("the captured stack doesn't include the panicking function:\n%s", got)
+	}
+	if v, _ := stre.StringField("handler"); v != "test" {
+		t.Errorf("got handler field %q, want %q", v, "test")
+	}
+
+	if err := FromPanic(nil); err != nil {
+		t.Errorf("got %v for a nil panic value, want nil", err)
+	}
+}
+
+func TestFromPanic_pkgErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"new", pkgerrors.New("invariant violated")},
+		{"wrapped", pkgerrors.Wrap(pkgerrors.WithMessage(pkgerrors.New("invariant violated"), "lib"), "checking")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stre := mustStructured(t, recoverFrom(func() { panic(tt.err) }))
+			if got := stre.stack.String(); !strings.HasPrefix(got, "github.com/ORBAT/erreur.TestFromPanic_pkgErrors\n\t") {
+				t.Errorf("the panic value's stack wasn't kept, got:\n%s", got)
+			}
+		})
+	}
+}
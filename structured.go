@@ -1,6 +1,11 @@
 package erreur
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/buffer"
 	"go.uber.org/zap/zapcore"
@@ -12,6 +17,9 @@ type Structured struct {
 	causer error
 	err    error
 	fields []zap.Field
+	stack  stack
+
+	fieldBuf *fieldBuf // set if fields was allocated by WithFields
 }
 
 // Structure returns a structured error with the given error as cause and the zap fields added as
@@ -24,36 +32,236 @@ func Structure(cause error, fields ...zap.Field) error {
 	return Structured{causer: cause, fields: fields}
 }
 
-// New returns a new structured error with the given message and fields
+// Enrich returns err with fields added to it without adding an error to its chain, for adding
+// context to an error on its way up without changing how it reads. If err is a structured error,
+// the fields are appended to its own fields like with WithFields, so the result has the same
+// message, cause and stack as err, and serializes as a single object with err's fields and the new
+// ones:
+// 	erreur.Enrich(erreur.Wrap(dbErr, "query failed", zap.String("table", "users")), zap.Int("userID", 1234))
+// is serialized as
+// 	{"msg":"query failed","table":"users","userID":1234,"cause":{...}}
+// whereas Structure would return a new error with err as its cause, serialized as err's message and
+// the new fields, with err's fields in a nested cause object. Other errors, including structured ones
+// wrapped in e.g. fmt.Errorf, are passed to Structure, as their wrappers would be lost if the fields
+// were added to the structured error inside them. Returns err as-is if there are no fields, and nil
+// if err is nil
+func Enrich(err error, fields ...zap.Field) error {
+	if err == nil || len(fields) == 0 {
+		return err
+	}
+	if stre, ok := err.(Structured); ok {
+		return stre.WithFields(fields...)
+	}
+	return Structure(err, fields...)
+}
+
+// New returns a new structured error with the given message and fields. If the environment
+// variable in StackEnvVar is set, the call stack is also captured, and if SetRecordTime is on, the
+// creation time is recorded
 func New(message string, fields ...zap.Field) error {
-	return Structured{err: String(message), fields: fields}
+	s := Structured{err: String(message), fields: withCreationFields(fields)}
+	if captureStacks {
+		s.stack = callers(0)
+	}
+	return s
+}
+
+// Errors returned by NewStrict for invalid field keys. See also ErrReservedKey
+const (
+	ErrEmptyKey     = String("field has an empty key")
+	ErrDuplicateKey = String("duplicate field key")
+)
+
+// NewStrict is like New, but checks the field keys first, for critical code paths where a typo in
+// a key shouldn't go unnoticed. If a field has an empty key, a reserved key (see ReservedKeys) or
+// the same key as an earlier field, it returns an error that matches ErrEmptyKey, ErrReservedKey or
+// ErrDuplicateKey with errors.Is and has the offending key and the index of the field in "key" and
+// "index" fields, and the structured error isn't created. Fields after a zap.Namespace field are in
+// their own scope, so they can reuse the keys of fields outside the namespace. Skipped fields are
+// ignored
+func NewStrict(message string, fields ...zap.Field) (Structured, error) {
+	seen := make(map[string]struct{}, len(fields))
+	inNamespace := false
+	for i, f := range fields {
+		if f.Type == zapcore.SkipType {
+			continue
+		}
+		if f.Key == "" {
+			return Structured{}, Structured{causer: ErrEmptyKey, fields: []zap.Field{zap.Int("index", i)}}
+		}
+		if !inNamespace && isReserved(f.Key) {
+			return Structured{}, Structured{causer: ErrReservedKey, fields: []zap.Field{zap.String("key", f.Key), zap.Int("index", i)}}
+		}
+		if _, dup := seen[f.Key]; dup {
+			return Structured{}, Structured{causer: ErrDuplicateKey, fields: []zap.Field{zap.String("key", f.Key), zap.Int("index", i)}}
+		}
+		if f.Type == zapcore.NamespaceType {
+			seen = make(map[string]struct{}, len(fields)-i)
+			inNamespace = true
+			continue
+		}
+		seen[f.Key] = struct{}{}
+	}
+
+	s := Structured{err: String(message), fields: withCreationFields(fields)}
+	if captureStacks {
+		s.stack = callers(0)
+	}
+	return s, nil
 }
 
-// Wrap cause with a new message and add context fields. Returns nil if cause is nil
+// Wrap cause with a new message and add context fields. Returns nil if cause is nil. If the
+// environment variable in StackEnvVar is set, the call stack is also captured, unless an error in
+// the cause chain already has one (see WrapWithStack). If SetRecordTime is on, the creation time is
+// recorded. See SetMaxWrapDepth for limiting the length of cause chains
 func Wrap(cause error, message string, fields ...zap.Field) error {
 	if cause == nil {
 		return nil
 	}
-	return Structured{causer: cause, err: String(message), fields: fields}
+	if collapsed, ok := collapseWrap(cause); ok {
+		return collapsed
+	}
+	s := Structured{causer: cause, err: String(message), fields: withCreationFields(fields)}
+	if captureStacks && !hasStack(cause) {
+		s.stack = callers(0)
+	}
+	return s
 }
 
-// JSONBuffer returns a go.uber.org/zap/buffer with the JSON serialization of s
+// Wrapef is like Wrap, but with a message formatted with fmt.Sprintf. The fields come before the
+// format string since both the fields and the format arguments can't be variadic:
+// 	erreur.Wrapef(err, []zap.Field{zap.String("table", table)}, "querying %s failed", table)
+// Returns nil if cause is nil. The fields slice isn't copied, so it shouldn't be modified afterwards
+func Wrapef(cause error, fields []zap.Field, format string, args ...interface{}) error {
+	if cause == nil {
+		return nil
+	}
+	if collapsed, ok := collapseWrap(cause); ok {
+		return collapsed
+	}
+	s := Structured{causer: cause, err: String(fmt.Sprintf(format, args...)), fields: withCreationFields(fields)}
+	if captureStacks && !hasStack(cause) {
+		s.stack = callers(0)
+	}
+	return s
+}
+
+// Errorf is a structured drop-in replacement for fmt.Errorf: it formats the message the same way,
+// and the error of a %w verb becomes the cause, so Error() returns exactly what fmt.Errorf would.
+// The result is a structured error, so fields can be added to it later and it's serialized like
+// Wrap's errors:
+// 	erreur.Errorf("loading user %d: %w", id, err)
+// has "loading user 1234" as its message and err as its cause. Several %w verbs make the errors a
+// multi-error cause, like with fmt.Errorf, and with no %w the error has no cause. Like with Wrap,
+// the call stack is captured if the environment variable in StackEnvVar is set
+func Errorf(format string, args ...interface{}) error {
+	wrapped := fmt.Errorf(format, args...)
+	full := wrapped.Error()
+
+	s := Structured{err: String(full)}
+	switch w := wrapped.(type) {
+	case wrapper:
+		cause := w.Unwrap()
+		if cause == nil {
+			break
+		}
+		s.causer = cause
+		// keep the cause out of the message when it's just appended to it, so that the cause
+		// isn't serialized twice
+		if own, ok := strings.CutSuffix(full, ": "+cause.Error()); ok && own != "" {
+			s.err = errorfMessage(own)
+		} else {
+			s.err = formattedMessage(full)
+		}
+	case multiWrapper:
+		s.causer = combined(w.Unwrap())
+		s.err = formattedMessage(full)
+	}
+
+	s.fields = withCreationFields(nil)
+	if captureStacks && (s.causer == nil || !hasStack(s.causer)) {
+		s.stack = callers(0)
+	}
+	return s
+}
+
+// formattedMessage is the message of an error created by Errorf whose message already contains the
+// message of its cause, so Error() shouldn't append it again
+type formattedMessage string
+
+// Error implements the error interface
+func (fm formattedMessage) Error() string {
+	return string(fm)
+}
+
+// errorfMessage is the message of an error created by Errorf whose format string joined it to the
+// message of its cause with ": ", so Error() joins them with that instead of the separator set with
+// SetErrorSeparator
+type errorfMessage string
+
+// Error implements the error interface
+func (em errorfMessage) Error() string {
+	return string(em)
+}
+
+// JSONBuffer returns a go.uber.org/zap/buffer with the JSON serialization of s. The buffer comes
+// from a pool, and the caller owns it: it must call Free() on it once done, and must not use the
+// buffer or anything returned by its Bytes() method after that, since the buffer will get reused
+// for something else. Use JSONBufferInto to write into a buffer you manage yourself, or JSON or
+// MarshalJSON if you don't want to deal with buffers at all
 func (s Structured) JSONBuffer() *buffer.Buffer {
+	return s.encodeJSON(zapcore.NewJSONEncoder(jsonEncConf))
+}
+
+// JSONBufferInto appends the JSON serialization of s to buf, which stays owned by the caller. This
+// lets the caller reuse a single buffer (calling Reset() between uses) for serializing many errors
+func (s Structured) JSONBufferInto(buf *buffer.Buffer) {
+	encoded := s.JSONBuffer()
+	_, _ = buf.Write(encoded.Bytes())
+	encoded.Free()
+}
+
+func (s Structured) encodeJSON(enc zapcore.Encoder) *buffer.Buffer {
+	return s.encodeJSONLevels(enc, 0)
+}
+
+// encodeJSONLevels is encodeJSON with at most levels errors of the cause chain serialized, or all of
+// them if levels is 0 (see JSONMaxDepth)
+func (s Structured) encodeJSONLevels(enc zapcore.Encoder, levels int) *buffer.Buffer {
+	msg, fields := serializedMessage(s.errorOrCause()), s.limitedFields(levels)
+	if maxOutputBytes > 0 {
+		return encodeLimited(msg, fields)
+	}
+	return encodeEntry(enc, msg, fields)
+}
+
+// encodeEntry encodes an entry with msg and fields with enc, HTML-escaping the output if
+// SetHTMLEscape is on
+func encodeEntry(enc zapcore.Encoder, msg string, fields []zapcore.Field) *buffer.Buffer {
 	// NOTE: ignoring the error here is safe with the current version of zap's JSON encoder, as it
 	// is always nil
-	buf, _ := zapcore.NewJSONEncoder(jsonEncConf).EncodeEntry(s.entry())
+	buf, _ := enc.EncodeEntry(zapcore.Entry{Message: msg}, fields)
+	if htmlEscape {
+		escaped := bufPool.Get()
+		appendHTMLEscaped(escaped, buf.Bytes())
+		buf.Free()
+		return escaped
+	}
 	return buf
 }
 
 // MarshalJSON implements json.Marshaler
 func (s Structured) MarshalJSON() ([]byte, error) {
-	buf := s.JSONBuffer()
+	return copyAndFree(s.JSONBuffer()), nil
+}
+
+// copyAndFree returns a copy of the contents of buf, and returns buf to its pool
+func copyAndFree(buf *buffer.Buffer) []byte {
 	bufBs := buf.Bytes()
 	bs := make([]byte, len(bufBs))
 	copy(bs, bufBs)
 	buf.Free()
-
-	return bs, nil
+	return bs
 }
 // JSON turns s into a JSON string. Shortcut for MarshalJSON that ignores the returned error (uses
 // zap's JSON encoder under the hood which never returns errors, so this is safe™)
@@ -62,34 +270,258 @@ func (s Structured) JSON() string {
 	return string(bs)
 }
 
-// Fields returns the fields of s and its causes (recursively)
+// Fields returns the fields of s and its causes (recursively). If the cause of s is a multi-error
+// like the ones returned by errors.Join, the errors it wraps are serialized as an "errors" array in
+// the cause object, or directly in s if s has no message of its own (i.e. it was created with
+// Structure or Combine). If SetChainAsArray is on, the causes are in a "chain" array instead of
+// nested cause objects. Skipped fields, e.g. zap.Skip() from a helper that only sometimes has a
+// field to add, are left out, so they take no slots in the returned slice
 func (s Structured) Fields() []zapcore.Field {
-	// reserve space for our fields and a potential cause object
-	fs := make([]zapcore.Field, 0, len(s.fields)+1)
+	return s.limitedFields(0)
+}
+
+// limitedFields returns the fields of s like Fields does, but with only up to levels errors of the
+// chain serialized (including s itself), or all of them if levels is 0. The causes that don't fit
+// are replaced with a marker field (see JSONMaxDepth)
+func (s Structured) limitedFields(levels int) []zapcore.Field {
+	// reserve space for our fields, a potential stack trace and a potential cause object
+	fs := make([]zapcore.Field, 0, len(s.fields)+2)
+
+	fs = s.appendLevelFields(fs)
+
+	if c, ok := s.structuredCause(); ok {
+		switch {
+		case levels == 0 && chainAsArray:
+			fs = append(fs, zap.Array("chain", chainArray{s: c}))
+		case levels == 0:
+			fs = append(fs, zap.Object("cause", c))
+		case levels == 1:
+			fs = append(fs, moreCausesField(c))
+		case chainAsArray:
+			fs = append(fs, zap.Array("chain", chainArray{s: c, levels: levels - 1}))
+		default:
+			fs = append(fs, zap.Object("cause", depthLimited{s: c, levels: levels - 1}))
+		}
+	}
 
-	fs = append(fs, s.fields...)
+	return fs
+}
 
-	if cause := s.Unwrap(); cause != nil {
-		if stre, ok := cause.(Structured); ok {
-			fs = append(fs, zap.Object("cause", stre))
+// structuredCause returns the cause of s for serialization if it's structured, or if it wraps a
+// structured error through errors that aren't (see liftStructured)
+func (s Structured) structuredCause() (Structured, bool) {
+	switch c := s.causer.(type) {
+	case nil, multiWrapper:
+		return Structured{}, false
+	case Structured:
+		return c, true
+	}
+	return liftStructured(s.causer)
+}
+
+// liftStructured returns the structured error in err's cause chain like AsStructured does, except
+// that when it's wrapped in errors that aren't structured, like in fmt.Errorf("context: %w", stre),
+// the messages those wrappers add aren't lost: the returned error has them (e.g. "context") as its
+// message, and the structured error as its cause
+func liftStructured(err error) (Structured, bool) {
+	if stre, ok := err.(Structured); ok {
+		return stre, stre.causer != nil || stre.err != nil
+	}
+	stre, ok := AsStructured(err)
+	if !ok {
+		return Structured{}, false
+	}
+	msg := strings.TrimSuffix(strings.TrimSuffix(err.Error(), stre.Error()), ": ")
+	if msg == "" {
+		return stre, true
+	}
+	return Structured{err: String(msg), causer: stre}, true
+}
+
+// appendLevelFields appends the fields of s to fs along with its stack trace and the errors of a
+// multi-error cause or a plain cause (see SetPlainCauseAsObject), i.e. everything except a
+// structured cause
+func (s Structured) appendLevelFields(fs []zapcore.Field) []zapcore.Field {
+	fs = s.appendOwnFields(fs)
+
+	if s.stack != nil {
+		fs = append(fs, zap.String("stacktrace", s.stack.String()))
+	}
+
+	if c, ok := s.causer.(multiWrapper); ok {
+		if s.err == nil { // the multi-error is all there is to s, so no need to nest it
+			fs = append(fs, zap.Array("errors", errorArray(c.Unwrap())))
+		} else {
+			fs = append(fs, zap.Object("cause", multiCause(c.Unwrap())))
+		}
+	} else if plainCauseAsObject && s.err != nil && s.causer != nil {
+		// an error created with Structure already has the message of a plain cause as its own
+		if _, ok := s.structuredCause(); !ok {
+			fs = append(fs, zap.Object("cause", plainError{s.causer}))
+		}
+	}
+	return fs
+}
+
+// chainArray serializes a structured error and its structured causes as an array with one object
+// per error, for SetChainAsArray. If levels isn't 0, only that many errors are serialized, followed
+// by a marker object for the rest
+type chainArray struct {
+	s      Structured
+	levels int
+}
+
+// MarshalLogArray implements zapcore.ArrayMarshaler
+func (ca chainArray) MarshalLogArray(ae zapcore.ArrayEncoder) error {
+	s := ca.s
+	for i := 1; ; i++ {
+		if err := ae.AppendObject(chainLevel(s)); err != nil {
+			return err
+		}
+		next, ok := s.structuredCause()
+		if !ok {
+			return nil
+		}
+		if i == ca.levels {
+			return ae.AppendObject(fieldObject{moreCausesField(next)})
+		}
+		s = next
+	}
+}
+
+// chainLevel serializes a single error of a chainArray
+type chainLevel Structured
+
+// MarshalLogObject implements zapcore.ObjectMarshaler
+func (cl chainLevel) MarshalLogObject(oe zapcore.ObjectEncoder) error {
+	s := Structured(cl)
+	oe.AddString(nestedMessageKey, serializedMessage(s.errorOrCause()))
+	for _, f := range s.appendLevelFields(make([]zapcore.Field, 0, len(s.fields)+2)) {
+		f.AddTo(oe)
+	}
+	return nil
+}
+
+// appendOwnFields appends the fields of s (but not its causes) to fs as they should be serialized,
+// i.e. with the serialization options applied
+func (s Structured) appendOwnFields(fs []zapcore.Field) []zapcore.Field {
+	if !typedFields && !groupByDotPrefix && fieldTransformer == nil {
+		return appendUnskipped(fs, closeNamespace(s.fields))
+	}
+
+	own := s.fields
+	if fieldTransformer != nil {
+		own = make([]zapcore.Field, len(s.fields))
+		for i, f := range s.fields {
+			if f.Type != zapcore.SkipType {
+				f = fieldTransformer(f)
+			}
+			own[i] = f
+		}
+	}
+	if typedFields {
+		untyped := own
+		own = make([]zapcore.Field, 0, len(untyped))
+		for _, f := range untyped {
+			if f.Type == zapcore.SkipType || f.Type == zapcore.NamespaceType {
+				own = append(own, f)
+				continue
+			}
+			own = append(own, zap.Object(f.Key, typedField(f)))
 		}
-		return fs
 	}
+	if groupByDotPrefix {
+		own = groupFields(own)
+	}
+	return appendUnskipped(fs, closeNamespace(own))
+}
 
-	if stre, ok := AsStructured(s.Unwrap()); ok {
-		fs = append(fs, zap.Object("cause", stre))
-		return fs
+// appendUnskipped appends the fields of own that aren't skipped to fs
+func appendUnskipped(fs, own []zapcore.Field) []zapcore.Field {
+	for _, f := range own {
+		if f.Type != zapcore.SkipType {
+			fs = append(fs, f)
+		}
 	}
+	return fs
+}
 
+// closeNamespace returns fs with the fields that follow a zap.Namespace field nested in an object
+// named after the namespace. A namespace applies to every field added after it, so without this the
+// cause and stack trace (and with Field, the rest of the log entry's fields) would end up in the
+// namespace as well. Namespaces inside the namespace are nested the same way, since zap's JSON
+// encoder doesn't close namespaces opened inside objects either. fs is returned as-is if it has no
+// namespace
+func closeNamespace(fs []zapcore.Field) []zapcore.Field {
+	for i, f := range fs {
+		if f.Type != zapcore.NamespaceType {
+			continue
+		}
+		closed := make([]zapcore.Field, i, i+1)
+		copy(closed, fs[:i])
+		return append(closed, zap.Object(f.Key, fieldObject(closeNamespace(fs[i+1:]))))
+	}
 	return fs
 }
 
+// groupFields returns fs with fields whose keys have a dotted prefix grouped into objects, so that
+// e.g. "db.host" and "db.port" become the fields "host" and "port" of a "db" object. Groups are
+// placed where their first field was, and nested prefixes like "db.conn.timeout" are grouped
+// recursively
+func groupFields(fs []zapcore.Field) []zapcore.Field {
+	type group struct {
+		pos    int // index of the group's placeholder in grouped
+		fields []zapcore.Field
+	}
+
+	grouped := make([]zapcore.Field, 0, len(fs))
+	var groups map[string]*group
+
+	for _, f := range fs {
+		dot := strings.IndexByte(f.Key, '.')
+		if dot <= 0 || dot == len(f.Key)-1 || f.Type == zapcore.SkipType || f.Type == zapcore.NamespaceType {
+			grouped = append(grouped, f)
+			continue
+		}
+
+		prefix := f.Key[:dot]
+		f.Key = f.Key[dot+1:]
+		if groups == nil {
+			groups = make(map[string]*group)
+		}
+		g, ok := groups[prefix]
+		if !ok {
+			g = &group{pos: len(grouped)}
+			groups[prefix] = g
+			grouped = append(grouped, zapcore.Field{})
+		}
+		g.fields = append(g.fields, f)
+	}
+
+	for prefix, g := range groups {
+		grouped[g.pos] = zap.Object(prefix, fieldObject(groupFields(g.fields)))
+	}
+	return grouped
+}
+
+// typedField serializes a field as an object with its value and type
+type typedField zapcore.Field
+
+// MarshalLogObject implements zapcore.ObjectMarshaler
+func (tf typedField) MarshalLogObject(oe zapcore.ObjectEncoder) error {
+	f := zapcore.Field(tf)
+	f.Key = "value"
+	f.AddTo(oe)
+	oe.AddString("type", fieldTypeName(f.Type))
+	return nil
+}
+
 // MarshalLogObject implements zapcore.ObjectMarshaler. This means that you can do the following:
 //   zap.Object("error", s)
 //
 // See Field for a convenience function
 func (s Structured) MarshalLogObject(oe zapcore.ObjectEncoder) error {
-	oe.AddString("msg", s.errorOrCause())
+	oe.AddString(nestedMessageKey, serializedMessage(s.errorOrCause()))
 	for _, field := range s.Fields() {
 		field.AddTo(oe)
 	}
@@ -102,11 +534,176 @@ func (s Structured) Unwrap() error {
 	return s.causer
 }
 
-// Cause is the same as Unwrap, but implements the interface in https://github.com/pkg/errors
+// ReplaceCause returns a copy of s with newCause as its cause, keeping the message and fields of s.
+// The old cause and everything below it are dropped entirely, so nothing of them ends up in the
+// output of Error() or in serialization. This is meant for translating errors at boundaries, e.g.
+// replacing a low-level error that contains internal details with a sanitized one.
+//
+// If s has no message of its own (it was created with Structure), its message is that of the cause,
+// so the message of newCause becomes the message of the returned error. Passing a nil newCause
+// removes the cause, except for errors created with Structure, which need a cause and are returned
+// as-is
+func (s Structured) ReplaceCause(newCause error) Structured {
+	if newCause == nil && s.err == nil {
+		return s
+	}
+	s.causer = newCause
+	return s
+}
+
+// WithRootMessage returns a copy of s with the message of its root cause replaced with message,
+// keeping everything above the root as it is. This is meant for scrubbing e.g. an internal detail
+// that leaked into the message of a low-level error, without losing the context the layers above
+// it added:
+// 	erreur.Wrap(erreur.Wrap(String("pq: password authentication failed for user admin"), "connecting"), "loading user")
+// becomes "loading user: connecting: database error" with WithRootMessage("database error").
+//
+// The root is the innermost structured error, or the cause of that error if it isn't structured, in
+// which case it's replaced with an error that has the new message but still matches the original
+// with errors.Is and errors.As. The fields of a structured root are kept
+func (s Structured) WithRootMessage(message string) Structured {
+	switch cause := s.causer.(type) {
+	case nil:
+		s.err = String(message)
+	case Structured:
+		s.causer = cause.WithRootMessage(message)
+	default:
+		s.causer = rootMessage{err: cause, msg: message}
+	}
+	return s
+}
+
+// rootMessage is a non-structured root cause with its message replaced by WithRootMessage
+type rootMessage struct {
+	err error
+	msg string
+}
+
+// Error implements the error interface
+func (rm rootMessage) Error() string {
+	return rm.msg
+}
+
+// Is makes errors.Is match the original error
+func (rm rootMessage) Is(target error) bool {
+	return errors.Is(rm.err, target)
+}
+
+// As makes errors.As match the original error
+func (rm rootMessage) As(target interface{}) bool {
+	return errors.As(rm.err, target)
+}
+
+// Cause is the same as Unwrap, but implements the causer interface in https://github.com/pkg/errors.
+// Note that like Unwrap, it returns the immediate cause of s, not the root cause. This is what
+// pkg/errors expects from the method: its errors.Cause function calls Cause repeatedly to get to the
+// root, so errors.Cause(s) does return the root cause of s. Use RootCause to do the same without
+// pkg/errors
 func (s Structured) Cause() error {
 	return s.causer
 }
 
+// RootCause returns the innermost error in err's cause chain, i.e. the last error reached by
+// unwrapping err. It follows both the standard library's Unwrap() error and pkg/errors' Cause()
+// error methods, so it works for chains that mix erreur, fmt.Errorf and pkg/errors wrappers. It
+// stops at multi-errors like the ones returned by errors.Join, since they have no single cause.
+// Returns err if it has no cause, and nil if err is nil
+func RootCause(err error) error {
+	for err != nil {
+		var next error
+		switch e := err.(type) {
+		case wrapper:
+			next = e.Unwrap()
+		case interface{ Cause() error }:
+			next = e.Cause()
+		}
+		if next == nil {
+			return err
+		}
+		err = next
+	}
+	return nil
+}
+
+// Find returns the first error in err's cause chain, starting from err itself, for which match
+// returns true, or nil if there's none. It's a more general errors.As for when the error to find
+// can't be described by a type alone, or when only some errors of a type should match:
+// 	opErr := erreur.Find(err, func(e error) bool {
+// 		oe, ok := e.(*net.OpError)
+// 		return ok && oe.Op == "dial"
+// 	})
+// Like RootCause, it follows both Unwrap() error and pkg/errors' Cause() error, and like errors.As,
+// it goes through the errors of multi-errors depth-first
+func Find(err error, match func(error) bool) error {
+	for err != nil {
+		if match(err) {
+			return err
+		}
+		switch e := err.(type) {
+		case wrapper:
+			err = e.Unwrap()
+		case interface{ Cause() error }:
+			err = e.Cause()
+		case multiWrapper:
+			for _, inner := range e.Unwrap() {
+				if found := Find(inner, match); found != nil {
+					return found
+				}
+			}
+			return nil
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// Time returns the time s was created at, if it was recorded (see SetRecordTime)
+func (s Structured) Time() (time.Time, bool) {
+	return s.TimeField(timeKey)
+}
+
+// withCreationFields returns fields with the fields recorded at creation time appended, i.e. the
+// current time if SetRecordTime is on, the goroutine ID if SetRecordGoroutineID is and the build
+// info if SetRecordBuildInfo is, and fields as-is if none is on. fields itself is never appended
+// to, as it could be the caller's slice
+func withCreationFields(fields []zap.Field) []zap.Field {
+	if !recordTime && !recordGoroutineID && !recordBuildInfo {
+		return fields
+	}
+	fs := make([]zap.Field, 0, len(fields)+4)
+	fs = append(fs, fields...)
+	return appendCreationFields(fs)
+}
+
+// appendCreationFields appends the fields recorded at creation time to fs
+func appendCreationFields(fs []zap.Field) []zap.Field {
+	if recordTime {
+		fs = append(fs, zap.Time(timeKey, now()))
+	}
+	if recordGoroutineID {
+		if id, ok := goroutineID(); ok {
+			fs = append(fs, zap.Uint64(goIDKey, id))
+		}
+	}
+	if recordBuildInfo {
+		if buildVersion != "" {
+			fs = append(fs, buildInfoField(versionKey, buildVersion))
+		}
+		if buildCommit != "" {
+			fs = append(fs, buildInfoField(commitKey, buildCommit))
+		}
+	}
+	return fs
+}
+
+// Message returns the message of s alone, without the messages of its causes that Error() includes.
+// For errors created with Structure, which have no message of their own, this is the message of the
+// cause
+func (s Structured) Message() string {
+	return s.errorOrCause()
+}
+
 func (s Structured) errorOrCause() string {
 	if s.err != nil {
 		return s.err.Error()
@@ -114,22 +711,94 @@ func (s Structured) errorOrCause() string {
 	return s.causer.Error()
 }
 
-// Error returns just the message of s, with no context fields
+// Error returns just the message of s, with no context fields. If IncludeFieldsInError is set, the
+// fields of s and its causes are included as well. See SetStripControlChars for messages with
+// control characters
 func (s Structured) Error() string {
+	if msg, ok := s.err.(String); ok && s.causer == nil && !IncludeFieldsInError && !stripControlChars {
+		// the message of a leaf error is returned as-is, without allocating, as Error() is often
+		// called on hot paths
+		return string(msg)
+	}
+	msg := s.errorString(IncludeFieldsInError)
+	if stripControlChars {
+		return stripControl(msg)
+	}
+	return msg
+}
+
+// errorString returns the message Error() returns before SetStripControlChars is applied, with the
+// fields of s and its structured causes in the " {key=value ...}" form if withFields is true
+func (s Structured) errorString(withFields bool) string {
+	suffix := ""
+	if withFields {
+		suffix = compactFields(s.fields)
+	}
+
+	if fm, ok := s.err.(formattedMessage); ok {
+		return string(fm) + suffix
+	}
 	if s.err != nil {
 		if s.causer == nil { // only an error but no cause, so return that
-			return s.err.Error()
+			return s.err.Error() + suffix
 		} else { // have an error and a cause for it, return both
-			return s.err.Error() + ": " + s.causer.Error()
+			sep := errorSeparator
+			if _, ok := s.err.(errorfMessage); ok {
+				sep = ": "
+			}
+			return s.err.Error() + suffix + sep + causeString(s.causer, withFields)
 		}
 	}
 
 	// just a cause, so created with Structure()
-	return s.causer.Error()
+	return causeString(s.causer, withFields) + suffix
 }
 
-func (s Structured) entry() (zapcore.Entry, []zapcore.Field) {
-	return zapcore.Entry{Message: s.errorOrCause()}, s.Fields()
+// causeString returns the message of cause for errorString
+func causeString(cause error, withFields bool) string {
+	if stre, ok := cause.(Structured); ok {
+		return stre.errorString(withFields)
+	}
+	return cause.Error()
+}
+
+// Plain returns a plain error with the message Error() would return without IncludeFieldsInError,
+// and nothing else: no fields, no cause chain for errors.Is or errors.As to find, and no structure
+// for erreur to serialize. This is a deliberate downgrade for returning errors across a trust
+// boundary, e.g. to API clients, without leaking the fields or the errors inside s. Note that a
+// non-structured wrapper in the chain, e.g. fmt.Errorf("...: %w", stre), includes the message of
+// the error it wraps as returned by its Error(), so with IncludeFieldsInError set, any fields in
+// that part of the chain are still included
+func (s Structured) Plain() error {
+	msg := s.errorString(false)
+	if stripControlChars {
+		msg = stripControl(msg)
+	}
+	return errors.New(msg)
+}
+
+// compactFields returns fs in the " {key=value ...}" form used by Error(), or an empty string if
+// none of fs has a value
+func compactFields(fs []zapcore.Field) string {
+	var sb strings.Builder
+	for _, f := range fs {
+		v, ok := fieldValue(f)
+		if !ok {
+			continue
+		}
+		if sb.Len() == 0 {
+			sb.WriteString(" {")
+		} else {
+			sb.WriteByte(' ')
+		}
+		fmt.Fprintf(&sb, "%s=%v", f.Key, v)
+	}
+
+	if sb.Len() == 0 {
+		return ""
+	}
+	sb.WriteByte('}')
+	return sb.String()
 }
 
 // AsStructured is a shortcut for extracting a structured error from e's error chain. If ok is
@@ -152,22 +821,35 @@ func AsStructured(e error) (err Structured, ok bool) {
 	return s, s.causer != nil || s.err != nil
 }
 
-// IsStructured returns true if e or any error in its cause chain is a Structured.
-// Shortcut for
+// IsStructured returns true if e or any error in its cause chain is a Structured. It's equivalent
+// to
 //   _, found := AsStructured(e)
+// but stops at the first Structured found without extracting it, so it's cheaper on hot paths
 func IsStructured(e error) bool {
-	_, found := AsStructured(e)
-	return found
+	for e != nil {
+		if stre, ok := e.(Structured); ok {
+			return stre.causer != nil || stre.err != nil
+		}
+
+		cause, ok := e.(wrapper)
+		if !ok {
+			return false
+		}
+		e = cause.Unwrap()
+	}
+	return false
 }
 
 // Field returns a zap field for err under the key "error". If err is nil, returns a no-op field. If
 // err is a structured error or has one in its error chain, returns a zap.Object field, if err is a
-// plain 'ol error, returns zap.Error
+// plain 'ol error, returns zap.Error. When the structured error is wrapped in errors that aren't
+// structured, like in fmt.Errorf("context: %w", stre), the message those add ("context") is the
+// message of the object, and the structured error is its cause
 func Field(err error) zapcore.Field {
 	if err == nil {
 		return zap.Skip()
 	}
-	stre, ok := AsStructured(err)
+	stre, ok := liftStructured(err)
 	if ok {
 		return zap.Object("error", stre)
 	}
@@ -184,12 +866,27 @@ func (es String) Error() string {
 	return string(es)
 }
 
+// fieldValue decodes the value of f the same way zap's encoders see it. ok is false for fields that
+// carry no value, like namespaces and skipped fields
+func fieldValue(f zapcore.Field) (v interface{}, ok bool) {
+	if f.Type == zapcore.SkipType || f.Type == zapcore.NamespaceType {
+		return nil, false
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	v, ok = enc.Fields[f.Key]
+	return v, ok
+}
+
 type wrapper interface {
 	Unwrap() error
 }
 
 var jsonEncConf zapcore.EncoderConfig
 
+// now returns the current time. Tests replace it to get deterministic times
+var now = time.Now
+
 func init() {
 	jsonEncConf = zap.NewProductionEncoderConfig()
 	jsonEncConf.CallerKey = ""
@@ -1,9 +1,17 @@
 package erreur
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
 
+	pkgerrors "github.com/pkg/errors"
 	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
 )
 
 func ExampleNew_zap() {
@@ -15,7 +23,7 @@ func ExampleNew_zap() {
 	// Output: {"level":"error","msg":"failed to load data","error":{"msg":"connection error","code":1234,"addr":"example.com"}}
 }
 
-func ExampleNew_JSON() {
+func ExampleNew_json() {
 	connErr := New("connection error", zap.Int("code", 1234), zap.String("addr", "example.com"))
 
 	// [...] elsewhere in your code
@@ -44,4 +52,619 @@ func ExampleWrap_cause() {
 	zap.NewExample().Error("failed to flush db", Field(finalErr))
 
 	// Output: {"level":"error","msg":"failed to flush db","error":{"msg":"failed to flush db","fieldThatGoes":"ping","cause":{"msg":"writing to file failed","fileName":"someFile"}}}
-}
\ No newline at end of file
+}
+func ExampleStructured_JSONBufferInto() {
+	errs := []error{
+		New("connection error", zap.String("addr", "example.com")),
+		New("timeout", zap.Int("attempt", 3)),
+	}
+
+	// one buffer, owned by us, reused for every error
+	buf := buffer.NewPool().Get()
+	defer buf.Free()
+	for _, err := range errs {
+		buf.Reset()
+		structured, _ := AsStructured(err)
+		structured.JSONBufferInto(buf)
+		fmt.Print(buf.String())
+	}
+
+	// Output:
+	// {"msg":"connection error","addr":"example.com"}
+	// {"msg":"timeout","attempt":3}
+}
+
+func TestStructured_JSONBufferInto(t *testing.T) {
+	stre := mustStructured(t, Wrap(New("inner", zap.Int("n", 1)), "outer"))
+
+	buf := buffer.NewPool().Get()
+	defer buf.Free()
+	buf.AppendString("prefix ")
+	stre.JSONBufferInto(buf)
+
+	if got, want := buf.String(), "prefix "+stre.JSON(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// the contents of buf must survive later serializations reusing pooled buffers
+	contents := buf.String()
+	for i := 0; i < 10; i++ {
+		_ = mustStructured(t, New("other", zap.Int("i", i))).JSON()
+	}
+	if got := buf.String(); got != contents {
+		t.Errorf("buffer was modified by later serializations, got\n%s\nwant\n%s", got, contents)
+	}
+}
+
+func TestStructured_Error_includeFields(t *testing.T) {
+	const sentinel = String("insufficient permissions")
+	cause := New("writing to file failed", zap.String("fileName", "someFile"), zap.Int("mode", 0600))
+	wrapped := Wrap(cause, "failed to flush db", zap.Bool("retried", true))
+	structured := Structure(sentinel, zap.String("user", "bob"))
+
+	tests := []struct {
+		name                  string
+		err                   error
+		wantPlain, wantFields string
+	}{
+		{"leaf", cause, "writing to file failed", "writing to file failed {fileName=someFile mode=384}"},
+		{"chain", wrapped,
+			"failed to flush db: writing to file failed",
+			"failed to flush db {retried=true}: writing to file failed {fileName=someFile mode=384}"},
+		{"structure", structured, "insufficient permissions", "insufficient permissions {user=bob}"},
+		{"no fields", New("no fields"), "no fields", "no fields"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.wantPlain {
+				t.Errorf("got Error() %q, want %q", got, tt.wantPlain)
+			}
+
+			IncludeFieldsInError = true
+			defer func() { IncludeFieldsInError = false }()
+
+			if got := tt.err.Error(); got != tt.wantFields {
+				t.Errorf("with IncludeFieldsInError, got Error() %q, want %q", got, tt.wantFields)
+			}
+		})
+	}
+
+	IncludeFieldsInError = true
+	defer func() { IncludeFieldsInError = false }()
+
+	if !errors.Is(Wrap(structured, "outer", zap.Int("n", 1)), sentinel) {
+		t.Error("errors.Is didn't find the sentinel with IncludeFieldsInError")
+	}
+	var target Structured
+	if !errors.As(wrapped, &target) {
+		t.Error("errors.As didn't find a Structured with IncludeFieldsInError")
+	}
+}
+
+func TestIsStructured(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"plain", String("plain"), false},
+		{"structured", New("structured"), true},
+		{"wrapped structured", fmt.Errorf("outer: %w", New("structured")), true},
+		{"wrapped plain", fmt.Errorf("outer: %w", String("plain")), false},
+		{"zero Structured", Structured{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsStructured(tt.err); got != tt.want {
+				t.Errorf("got IsStructured %v, want %v", got, tt.want)
+			}
+			if _, got := AsStructured(tt.err); got != tt.want {
+				t.Errorf("IsStructured and AsStructured disagree: AsStructured found %v", got)
+			}
+		})
+	}
+}
+
+func TestStructured_Error_leafAllocs(t *testing.T) {
+	leaf := New("connection error", zap.Int("code", 1234))
+	if allocs := testing.AllocsPerRun(100, func() { benchMessage = leaf.Error() }); allocs != 0 {
+		t.Errorf("got %v allocations for Error() on a leaf error, want 0", allocs)
+	}
+}
+
+var benchMessage string
+
+func BenchmarkStructured_Error(b *testing.B) {
+	b.Run("leaf", func(b *testing.B) {
+		err := New("connection error", zap.Int("code", 1234))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			benchMessage = err.Error()
+		}
+	})
+
+	b.Run("wrapped", func(b *testing.B) {
+		err := Wrap(New("connection error", zap.Int("code", 1234)), "query failed")
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			benchMessage = err.Error()
+		}
+	})
+}
+
+func deepChain(depth int) error {
+	err := New("root cause", zap.String("key", "value"))
+	for i := 0; i < depth; i++ {
+		err = fmt.Errorf("layer %d: %w", i, err)
+	}
+	return err
+}
+
+var benchFound bool
+
+func BenchmarkIsStructured(b *testing.B) {
+	err := deepChain(20)
+
+	b.Run("IsStructured", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			benchFound = IsStructured(err)
+		}
+	})
+
+	b.Run("AsStructured", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, benchFound = AsStructured(err)
+		}
+	})
+}
+
+func TestStructured_ReplaceCause(t *testing.T) {
+	leaky := Wrap(String("pq: password authentication failed for user \"admin\""), "connecting failed", zap.String("dsn", "postgres://admin:hunter2@db"))
+	orig := mustStructured(t, Wrap(leaky, "loading user failed", zap.Int("userID", 1234)))
+	origJSON := orig.JSON()
+
+	replaced := orig.ReplaceCause(New("database unavailable", zap.Bool("retryable", true)))
+
+	const want = `{"msg":"loading user failed","userID":1234,"cause":{"msg":"database unavailable","retryable":true}}` + "\n"
+	if got := replaced.JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+	if got, want := replaced.Error(), "loading user failed: database unavailable"; got != want {
+		t.Errorf("got Error() %q, want %q", got, want)
+	}
+	if errors.Is(replaced, String("pq: password authentication failed for user \"admin\"")) {
+		t.Error("the old cause can still be found with errors.Is")
+	}
+	if orig.JSON() != origJSON {
+		t.Error("original was modified")
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		const want = `{"msg":"loading user failed","userID":1234}` + "\n"
+		if got := orig.ReplaceCause(nil).JSON(); got != want {
+			t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+		}
+		if got, want := orig.ReplaceCause(nil).Error(), "loading user failed"; got != want {
+			t.Errorf("got Error() %q, want %q", got, want)
+		}
+	})
+
+	t.Run("structure", func(t *testing.T) {
+		stre := mustStructured(t, Structure(String("internal detail"), zap.Int("n", 1)))
+		if got, want := stre.ReplaceCause(String("sanitized")).Error(), "sanitized"; got != want {
+			t.Errorf("got Error() %q, want %q", got, want)
+		}
+		if got, want := stre.ReplaceCause(nil).Error(), "internal detail"; got != want {
+			t.Errorf("got Error() %q, want %q", got, want)
+		}
+	})
+}
+
+func TestStructured_Message(t *testing.T) {
+	cause := New("connection refused", zap.String("addr", "db:5432"))
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"New", cause, "connection refused"},
+		{"Wrap", Wrap(cause, "loading user failed"), "loading user failed"},
+		{"Wrap twice", Wrap(Wrap(cause, "query failed"), "loading user failed"), "loading user failed"},
+		{"Structure", Structure(String("insufficient permissions"), zap.Int("uid", 1)), "insufficient permissions"},
+		{"Structure of wrapped", Structure(Wrap(cause, "query failed")), "query failed: connection refused"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mustStructured(t, tt.err).Message(); got != tt.want {
+				t.Errorf("got Message() %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapef(t *testing.T) {
+	const table = "users"
+	cause := New("dial failed", zap.String("host", "db"))
+	err := Wrapef(cause, []zap.Field{zap.String("table", table), zap.Int("attempt", 3)}, "querying %s failed after %d attempts", table, 3)
+
+	if got, want := err.Error(), "querying users failed after 3 attempts: dial failed"; got != want {
+		t.Errorf("got Error() %q, want %q", got, want)
+	}
+
+	const want = `{"msg":"querying users failed after 3 attempts","table":"users","attempt":3,"cause":{"msg":"dial failed","host":"db"}}` + "\n"
+	if got := mustStructured(t, err).JSON(); got != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+
+	if got := mustStructured(t, Wrapef(cause, nil, "no fields")).JSON(); got != `{"msg":"no fields","cause":{"msg":"dial failed","host":"db"}}`+"\n" {
+		t.Errorf("got JSON without fields\n%s", got)
+	}
+	if err := Wrapef(nil, nil, "message %d", 1); err != nil {
+		t.Errorf("got %v for a nil cause, want nil", err)
+	}
+}
+
+func TestField_nonStructuredWrappers(t *testing.T) {
+	inner := New("query failed", zap.String("table", "users"))
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"fmt wrapper",
+			fmt.Errorf("loading profile: %w", inner),
+			`{"msg":"failed to load data","error":{"msg":"loading profile","cause":{"msg":"query failed","table":"users"}}}`},
+		{"several wrappers",
+			fmt.Errorf("handler: %w", fmt.Errorf("loading profile: %w", inner)),
+			`{"msg":"failed to load data","error":{"msg":"handler: loading profile","cause":{"msg":"query failed","table":"users"}}}`},
+		{"wrapper without a message",
+			fmt.Errorf("%w", inner),
+			`{"msg":"failed to load data","error":{"msg":"query failed","table":"users"}}`},
+		{"wrapper with a suffix",
+			fmt.Errorf("%w (retrying)", inner),
+			`{"msg":"failed to load data","error":{"msg":"query failed (retrying)","cause":{"msg":"query failed","table":"users"}}}`},
+		{"in a cause",
+			Wrap(fmt.Errorf("loading profile: %w", inner), "request failed"),
+			`{"msg":"failed to load data","error":{"msg":"request failed","cause":{"msg":"loading profile","cause":{"msg":"query failed","table":"users"}}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := logLine(tt.err); got != tt.want+"\n" {
+				t.Errorf("got log line\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructured_Cause(t *testing.T) {
+	const root = String("connection refused")
+	middle := Wrap(root, "dial failed")
+	outer := Wrap(middle, "query failed")
+
+	if got := mustStructured(t, outer).Cause(); !Equal(got, middle) {
+		t.Errorf("Cause() returned %v, want the immediate cause %v", got, middle)
+	}
+	if got := pkgerrors.Cause(outer); got != root {
+		t.Errorf("pkg/errors.Cause returned %v, want the root cause %v", got, root)
+	}
+}
+
+func TestRootCause(t *testing.T) {
+	const root = String("connection refused")
+	joined := errors.Join(root, String("timeout"))
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"structured", Wrap(Wrap(root, "dial failed"), "query failed"), root},
+		{"fmt", fmt.Errorf("handler: %w", Wrap(root, "dial failed")), root},
+		{"pkg/errors", Wrap(pkgerrors.Wrap(Structure(root), "dial failed"), "query failed"), root},
+		{"structure", Structure(root), root},
+		{"stops at multi-errors", Wrap(joined, "failed"), joined},
+		{"no cause", root, root},
+		{"nil", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RootCause(tt.err); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	leaf := New("leaf")
+	if got := RootCause(Wrap(leaf, "outer")); !Equal(got, leaf) {
+		t.Errorf("got %v, want the structured leaf %v", got, leaf)
+	}
+}
+
+func TestErrorf(t *testing.T) {
+	const sentinel = String("no rows")
+	cause := Wrap(sentinel, "query failed", zap.String("table", "users"))
+
+	tests := []struct {
+		name      string
+		err       error
+		wantJSON  string
+		wantCause error
+	}{
+		{"trailing %w",
+			Errorf("loading user %d: %w", 1234, cause),
+			`{"msg":"loading user 1234","cause":{"msg":"query failed","table":"users"}}`,
+			cause},
+		{"%w in the middle",
+			Errorf("%w (after %d attempts)", cause, 3),
+			`{"msg":"query failed: no rows (after 3 attempts)","cause":{"msg":"query failed","table":"users"}}`,
+			cause},
+		{"no %w",
+			Errorf("user %d not found", 1234),
+			`{"msg":"user 1234 not found"}`,
+			nil},
+		{"several %w",
+			Errorf("both failed: %w, %w", sentinel, String("timeout")),
+			`{"msg":"both failed: no rows, timeout","cause":{"errors":[{"msg":"no rows"},{"msg":"timeout"}]}}`,
+			nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stre := mustStructured(t, tt.err)
+			if got := stre.JSON(); got != tt.wantJSON+"\n" {
+				t.Errorf("got JSON\n%s\nwant\n%s", got, tt.wantJSON)
+			}
+			if tt.wantCause != nil && !Equal(stre.Unwrap(), tt.wantCause) {
+				t.Errorf("got cause %v, want %v", stre.Unwrap(), tt.wantCause)
+			}
+			if !errors.Is(tt.err, sentinel) && tt.name != "no %w" {
+				t.Error("errors.Is didn't find the sentinel")
+			}
+		})
+	}
+
+	// Error() matches fmt.Errorf exactly
+	for _, args := range [][]interface{}{
+		{"loading user %d: %w", 1234, cause},
+		{"%w (after %d attempts)", cause, 3},
+		{"user %d not found", 1234},
+		{"both failed: %w, %w", sentinel, String("timeout")},
+	} {
+		format := args[0].(string)
+		if got, want := Errorf(format, args[1:]...).Error(), fmt.Errorf(format, args[1:]...).Error(); got != want {
+			t.Errorf("Errorf(%q) got Error() %q, fmt.Errorf gives %q", format, got, want)
+		}
+	}
+
+	withField := mustStructured(t, Errorf("loading user: %w", cause)).SetField(zap.Int("userID", 1234))
+	const wantField = `{"msg":"loading user","userID":1234,"cause":{"msg":"query failed","table":"users"}}` + "\n"
+	if got := withField.JSON(); got != wantField {
+		t.Errorf("got JSON with a field added\n%s\nwant\n%s", got, wantField)
+	}
+}
+
+func TestStructured_namespace(t *testing.T) {
+	root := New("dial failed", zap.String("host", "db"))
+	err := mustStructured(t, Wrap(root, "query failed", zap.Int("status", 500), zap.Namespace("db"), zap.String("table", "users"), zap.Namespace("opts"), zap.Bool("ro", true)))
+
+	const want = `{"msg":"query failed","status":500,"db":{"table":"users","opts":{"ro":true}},"cause":{"msg":"dial failed","host":"db"}}` + "\n"
+	if got := err.JSON(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	const wantLine = `{"msg":"failed to load data","error":{"msg":"query failed","status":500,"db":{"table":"users","opts":{"ro":true}},"cause":{"msg":"dial failed","host":"db"}},"user":"bob"}` + "\n"
+	if got := logFields(Field(err), zap.String("user", "bob")); got != wantLine {
+		t.Errorf("got log line\n%s\nwant\n%s", got, wantLine)
+	}
+}
+
+func TestStructured_WithRootMessage(t *testing.T) {
+	const leaked = String("pq: password authentication failed for user admin")
+	err := mustStructured(t, Wrap(Wrap(New("connect failed", zap.String("host", "db")), "query failed", zap.String("table", "users")), "loading user"))
+
+	const want = `{"msg":"loading user","cause":{"msg":"query failed","table":"users","cause":{"msg":"database error","host":"db"}}}` + "\n"
+	if got := err.WithRootMessage("database error").JSON(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := err.Error(); got != "loading user: query failed: connect failed" {
+		t.Errorf("the original error was modified: %q", got)
+	}
+
+	plainRoot := mustStructured(t, Wrap(Wrap(leaked, "connecting"), "loading user")).WithRootMessage("database error")
+	if got := plainRoot.Error(); got != "loading user: connecting: database error" {
+		t.Errorf("got Error() %q", got)
+	}
+	if strings.Contains(plainRoot.JSON(), "password") {
+		t.Errorf("the root message leaked: %s", plainRoot.JSON())
+	}
+	if !errors.Is(plainRoot, leaked) {
+		t.Error("errors.Is doesn't match the replaced root")
+	}
+
+	structured := mustStructured(t, Structure(leaked, zap.Int("n", 1))).WithRootMessage("database error")
+	if got := structured.Error(); got != "database error" {
+		t.Errorf("got Error() %q for an error created with Structure", got)
+	}
+}
+
+func TestNewStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []zap.Field
+		wantErr error
+		wantKey string
+	}{
+		{"valid", []zap.Field{zap.String("table", "users"), zap.Skip(), zap.Int("port", 5432)}, nil, ""},
+		{"empty key", []zap.Field{zap.String("table", "users"), zap.Int("", 5432)}, ErrEmptyKey, ""},
+		{"duplicate key", []zap.Field{zap.String("table", "users"), zap.Int("port", 1), zap.String("table", "orgs")}, ErrDuplicateKey, "table"},
+		{"key reused in a namespace", []zap.Field{zap.String("host", "a"), zap.Namespace("replica"), zap.String("host", "b")}, nil, ""},
+		{"duplicate namespace", []zap.Field{zap.String("db", "a"), zap.Namespace("db")}, ErrDuplicateKey, "db"},
+		{"empty namespace", []zap.Field{zap.Namespace("")}, ErrEmptyKey, ""},
+		{"reserved key", []zap.Field{zap.String("table", "users"), zap.String("cause", "typo")}, ErrReservedKey, "cause"},
+		{"reserved key in a namespace", []zap.Field{zap.Namespace("db"), zap.String("cause", "fine")}, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewStrict("query failed", tt.fields...)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("got error %v", err)
+				}
+				if !Equal(s, New("query failed", tt.fields...)) {
+					t.Errorf("got %s, want the same error as New", s.JSON())
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if key, _ := mustStructured(t, err).StringField("key"); key != tt.wantKey {
+				t.Errorf("got key %q, want %q", key, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestStructured_Fields_skipped(t *testing.T) {
+	maybe := func(add bool) zap.Field {
+		if !add {
+			return zap.Skip()
+		}
+		return zap.Bool("retried", true)
+	}
+	err := mustStructured(t, Wrap(New("dial failed", zap.Skip(), zap.String("host", "db")), "query failed",
+		zap.Skip(), zap.Int("port", 5432), maybe(false), zap.String("table", "users"), maybe(true)))
+
+	fs := err.Fields()
+	wantKeys := []string{"port", "table", "retried", "cause"}
+	if got := fieldKeys(fs); !equalStrings(got, wantKeys) {
+		t.Errorf("got keys %v, want %v", got, wantKeys)
+	}
+
+	const want = `{"msg":"query failed","port":5432,"table":"users","retried":true,"cause":{"msg":"dial failed","host":"db"}}` + "\n"
+	if got := err.JSON(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	SetFieldTransformer(func(f zapcore.Field) zapcore.Field {
+		if f.Key == "table" {
+			return zap.Skip()
+		}
+		return f
+	})
+	defer SetFieldTransformer(nil)
+	if got := fieldKeys(err.Fields()); !equalStrings(got, []string{"port", "retried", "cause"}) {
+		t.Errorf("got keys %v with a field transformed to zap.Skip()", got)
+	}
+}
+
+func TestFind(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: String("connection refused")}
+	readErr := &net.OpError{Op: "read", Net: "tcp", Err: String("connection reset")}
+	chain := Wrap(fmt.Errorf("handler: %w", pkgerrors.Wrap(Wrap(errors.Join(readErr, dialErr), "query failed"), "db")), "request failed")
+
+	isDial := func(e error) bool {
+		oe, ok := e.(*net.OpError)
+		return ok && oe.Op == "dial"
+	}
+	if got := Find(chain, isDial); got != dialErr {
+		t.Errorf("got %v, want the dial error", got)
+	}
+
+	isOpErr := func(e error) bool {
+		_, ok := e.(*net.OpError)
+		return ok
+	}
+	if got := Find(chain, isOpErr); got != readErr {
+		t.Errorf("got %v, want the first net.OpError", got)
+	}
+
+	isStructured := func(e error) bool {
+		_, ok := e.(Structured)
+		return ok
+	}
+	if got := Find(chain, isStructured); !Equal(got, chain) {
+		t.Errorf("got %v, want err itself", got)
+	}
+	if got := Find(chain, func(error) bool { return false }); got != nil {
+		t.Errorf("got %v when nothing matches, want nil", got)
+	}
+	if got := Find(nil, isOpErr); got != nil {
+		t.Errorf("got %v for nil, want nil", got)
+	}
+}
+
+func TestStructured_Plain(t *testing.T) {
+	const sentinel = String("no rows")
+	err := mustStructured(t, Wrap(Wrap(sentinel, "query failed", zap.String("table", "users")), "loading user", zap.Int("userID", 1234)))
+
+	IncludeFieldsInError = true
+	defer func() { IncludeFieldsInError = false }()
+
+	plain := err.Plain()
+	if got, want := plain.Error(), "loading user: query failed: no rows"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := err.Error(); got != "loading user {userID=1234}: query failed {table=users}: no rows" {
+		t.Errorf("Error() changed: %q", got)
+	}
+	if errors.Is(plain, sentinel) || errors.Unwrap(plain) != nil {
+		t.Error("the plain error still has a cause chain")
+	}
+	if IsStructured(plain) {
+		t.Error("the plain error has structured data")
+	}
+	if got := Field(plain); got.Type != zapcore.ErrorType {
+		t.Errorf("got field type %v for the plain error, want a plain error field", got.Type)
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	dbErr := New("connection reset")
+	query := Wrap(dbErr, "query failed", zap.String("table", "users"))
+
+	enriched := mustStructured(t, Enrich(query, zap.Int("userID", 1234)))
+	const want = `{"msg":"query failed","table":"users","userID":1234,"cause":{"msg":"connection reset"}}` + "\n"
+	if got := enriched.JSON(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if enriched.Error() != query.Error() || !Equal(enriched.Unwrap(), dbErr) {
+		t.Errorf("got Error() %q and cause %v, want them unchanged", enriched.Error(), enriched.Unwrap())
+	}
+	if len(mustStructured(t, query).fields) != 1 {
+		t.Error("the enriched error was modified")
+	}
+
+	// Structure adds a level to the chain instead
+	const wantStructure = `{"msg":"query failed: connection reset","userID":1234,"cause":{"msg":"query failed","table":"users","cause":{"msg":"connection reset"}}}` + "\n"
+	if got := mustStructured(t, Structure(query, zap.Int("userID", 1234))).JSON(); got != wantStructure {
+		t.Errorf("got\n%s\nwant\n%s for Structure", got, wantStructure)
+	}
+
+	wrapped := fmt.Errorf("loading profile: %w", Wrap(io.ErrUnexpectedEOF, "reading failed"))
+	plainEnriched := mustStructured(t, Enrich(wrapped, zap.Int("userID", 1234)))
+	if plainEnriched.Unwrap() != wrapped || !errors.Is(plainEnriched, io.ErrUnexpectedEOF) {
+		t.Errorf("the non-structured wrapper was lost: %v", plainEnriched.Unwrap())
+	}
+	if got, want := Enrich(io.EOF, zap.Int("offset", 42)).Error(), "EOF"; got != want {
+		t.Errorf("got Error() %q for a plain error, want %q", got, want)
+	}
+
+	if Enrich(nil, zap.Int("userID", 1234)) != nil {
+		t.Error("got a non-nil error for a nil error")
+	}
+	if !Equal(Enrich(query), query) {
+		t.Error("an error without new fields was changed")
+	}
+}
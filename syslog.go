@@ -0,0 +1,33 @@
+//go:build !windows && !plan9
+
+package erreur
+
+import (
+	"log/syslog"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// syslogSeverities maps zap levels to syslog severities
+var syslogSeverities = map[zapcore.Level]syslog.Priority{
+	zapcore.DebugLevel:  syslog.LOG_DEBUG,
+	zapcore.InfoLevel:   syslog.LOG_INFO,
+	zapcore.WarnLevel:   syslog.LOG_WARNING,
+	zapcore.ErrorLevel:  syslog.LOG_ERR,
+	zapcore.DPanicLevel: syslog.LOG_CRIT,
+	zapcore.PanicLevel:  syslog.LOG_ALERT,
+	zapcore.FatalLevel:  syslog.LOG_EMERG,
+}
+
+// SyslogSeverity returns the syslog severity matching the level set with WithLevel on s or an error
+// in its cause chain (see LevelOf), e.g. LOG_WARNING for the warn level. Errors with no level are
+// LOG_ERR. The result can be combined with a facility and used with e.g. syslog.New:
+// 	w, err := syslog.New(stre.SyslogSeverity()|syslog.LOG_DAEMON, "myapp")
+// log/syslog isn't available on Windows and Plan 9, so neither is SyslogSeverity
+func (s Structured) SyslogSeverity() syslog.Priority {
+	level, _ := LevelOf(s)
+	if sev, ok := syslogSeverities[level]; ok {
+		return sev
+	}
+	return syslog.LOG_ERR
+}
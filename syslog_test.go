@@ -0,0 +1,37 @@
+//go:build !windows && !plan9
+
+package erreur
+
+import (
+	"log/syslog"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestStructured_SyslogSeverity(t *testing.T) {
+	base := mustStructured(t, New("disk almost full"))
+
+	tests := []struct {
+		name string
+		s    Structured
+		want syslog.Priority
+	}{
+		{"no level", base, syslog.LOG_ERR},
+		{"debug", base.WithLevel(zapcore.DebugLevel), syslog.LOG_DEBUG},
+		{"info", base.WithLevel(zapcore.InfoLevel), syslog.LOG_INFO},
+		{"warn", base.WithLevel(zapcore.WarnLevel), syslog.LOG_WARNING},
+		{"error", base.WithLevel(zapcore.ErrorLevel), syslog.LOG_ERR},
+		{"dpanic", base.WithLevel(zapcore.DPanicLevel), syslog.LOG_CRIT},
+		{"fatal", base.WithLevel(zapcore.FatalLevel), syslog.LOG_EMERG},
+		{"level of a cause", mustStructured(t, Wrap(base.WithLevel(zapcore.WarnLevel), "cleanup failed")), syslog.LOG_WARNING},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.SyslogSeverity(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}